@@ -3,18 +3,30 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // schedule timezones must resolve in minimal images
 
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/api"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/config"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/history"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/logging"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/scheduler"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/tracing"
+	"k8s.io/client-go/kubernetes"
 )
 
+// historyFlushInterval is how often replica history is persisted.
+const historyFlushInterval = 10 * time.Second
+
 func main() {
 	os.Exit(run())
 }
@@ -22,18 +34,121 @@ func main() {
 func run() int {
 	cfg, err := config.Load()
 	if err != nil {
-		log.Printf("load config: %v", err)
+		slog.Error("load config", "error", err)
 		return 1
 	}
 
-	km, err := kube.NewManager(cfg.Namespace)
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
 	if err != nil {
-		log.Printf("failed to init kubernetes manager: %v", err)
+		slog.Error("configure logging", "error", err)
+		return 1
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		slog.Error("configure tracing", "error", err)
+		return 1
+	}
+	defer func() {
+		// Flush pending spans; bounded so a dead collector cannot block exit.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("shutdown tracing", "error", err)
+		}
+	}()
+
+	client, err := kube.NewClientset()
+	if err != nil {
+		slog.Error("failed to init kubernetes client", "error", err)
+		return 1
+	}
+
+	hist, err := newHistory(cfg, client)
+	if err != nil {
+		slog.Error("configure replica history", "error", err)
+		return 1
+	}
+	histStop := make(chan struct{})
+	histDone := make(chan struct{})
+	go func() {
+		defer close(histDone)
+		hist.Run(historyFlushInterval, histStop)
+	}()
+	defer func() {
+		close(histStop)
+		<-histDone
+	}()
+
+	km, err := kube.NewManager(client, cfg.Namespace, kube.Options{
+		ManagedOnly: cfg.ManagedOnly,
+		ManagedKey:  cfg.ManagedKey,
+		Observer:    hist.Observe,
+		StaleAfter:  cfg.CacheStaleAfter,
+		Limits: kube.ChangeLimits{
+			Cooldown:       cfg.ChangeCooldown,
+			MaxStep:        int32(cfg.MaxStep),
+			MaxStepPercent: int32(cfg.MaxStepPercent),
+		},
+		ReplicaBudget:  int64(cfg.ReplicaBudget),
+		QuotaCheck:     cfg.QuotaCheck,
+		HPACheck:       cfg.HPACheck,
+		HPAOverrideTTL: cfg.HPAOverrideTTL,
+		PDBCheck:       cfg.PDBMode == config.PDBModeWarn || cfg.PDBMode == config.PDBModeReject,
+	})
+	if err != nil {
+		slog.Error("failed to init kubernetes manager", "error", err)
 		return 1
 	}
 	defer km.Shutdown()
 
-	s := api.New(cfg, km)
+	auditor, err := newAuditLogger(cfg)
+	if err != nil {
+		slog.Error("configure audit log", "error", err)
+		return 1
+	}
+	defer auditor.Close()
+
+	var freezes *freeze.Calendar
+	if cfg.FreezeFile != "" {
+		if freezes, err = freeze.Load(cfg.FreezeFile); err != nil {
+			slog.Error("configure freeze windows", "error", err)
+			return 1
+		}
+		slog.Info("freeze windows enabled", "windows", len(freezes.Windows()), "file", cfg.FreezeFile)
+	}
+
+	sched, err := newScheduler(cfg, km, auditor, freezes)
+	if err != nil {
+		slog.Error("configure scheduled scaling", "error", err)
+		return 1
+	}
+	if sched != nil {
+		schedCtx, stopSched := context.WithCancel(context.Background())
+		schedDone := make(chan struct{})
+		go func() {
+			defer close(schedDone)
+			sched.Run(schedCtx)
+		}()
+		defer func() {
+			stopSched()
+			<-schedDone
+		}()
+	}
+
+	opts := []api.Option{
+		api.WithAuditLogger(auditor),
+		api.WithHistory(hist),
+		api.WithScheduler(sched),
+		api.WithFreezeCalendar(freezes),
+	}
+	approvalRules := approval.Rules{ScaleToZero: cfg.ApprovalScaleToZero, MaxChangePercent: cfg.ApprovalMaxChangePercent}
+	if approvalRules.Enabled() {
+		opts = append(opts, api.WithApprovals(approval.NewQueue(cfg.ApprovalTTL, nil), approvalRules))
+	}
+
+	s := api.New(cfg, km, opts...)
 
 	// Run server in background.
 	errCh := make(chan error, 1)
@@ -49,15 +164,15 @@ func run() int {
 	// Wait for signal or server exit.
 	select {
 	case sig := <-sigCh:
-		log.Printf("received signal %s, shutting down", sig)
+		slog.Info("received signal, shutting down", "signal", sig.String())
 
 	case err := <-errCh:
 		// Start() returned before we even got a signal.
 		if err == nil || errors.Is(err, http.ErrServerClosed) {
-			log.Printf("server stopped")
+			slog.Info("server stopped")
 			return 0
 		}
-		log.Printf("server error: %v", err)
+		slog.Error("server error", "error", err)
 		return 1
 	}
 
@@ -66,7 +181,7 @@ func run() int {
 	defer cancel()
 
 	if err := s.Shutdown(ctx); err != nil {
-		log.Printf("shutdown error: %v", err)
+		slog.Error("shutdown error", "error", err)
 		return 1
 	}
 
@@ -74,7 +189,7 @@ func run() int {
 	select {
 	case err := <-errCh:
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Printf("server error during shutdown: %v", err)
+			slog.Error("server error during shutdown", "error", err)
 			return 1
 		}
 	case <-time.After(2 * time.Second):
@@ -83,3 +198,55 @@ func run() int {
 
 	return 0
 }
+
+// newAuditLogger opens the audit sinks selected by cfg.AuditSinks.
+func newAuditLogger(cfg config.Config) (*audit.Logger, error) {
+	var sinks []audit.Sink
+	for _, name := range cfg.AuditSinks {
+		switch name {
+		case audit.SinkStdout:
+			sinks = append(sinks, audit.NewWriterSink(os.Stdout))
+		case audit.SinkFile:
+			f, err := audit.NewFileSink(cfg.AuditFile, int64(cfg.AuditFileMaxSizeMB)<<20, cfg.AuditFileMaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, f)
+		}
+	}
+	return audit.New(cfg.AuditFailClosed, sinks...), nil
+}
+
+// newHistory loads replica history from the backend selected by cfg.HistoryBackend.
+func newHistory(cfg config.Config, client kubernetes.Interface) (*history.Recorder, error) {
+	var backend history.Backend
+	switch cfg.HistoryBackend {
+	case history.BackendFile:
+		backend = history.FileBackend{Path: cfg.HistoryFile}
+	case history.BackendConfigMap:
+		backend = history.ConfigMapBackend{Client: client, Namespace: cfg.Namespace, Name: cfg.HistoryConfigMap}
+	}
+	return history.New(backend, cfg.HistoryMaxEntries)
+}
+
+// newScheduler loads scheduled scaling rules from cfg.ScheduleFile. It returns nil when
+// no schedule file is configured.
+func newScheduler(cfg config.Config, store kube.Store, auditor *audit.Logger, freezes *freeze.Calendar) (*scheduler.Scheduler, error) {
+	if cfg.ScheduleFile == "" {
+		return nil, nil
+	}
+	rules, err := scheduler.LoadRules(cfg.ScheduleFile)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("scheduled scaling enabled", "rules", len(rules), "file", cfg.ScheduleFile)
+	return scheduler.New(rules, scheduler.Options{
+		Store:         store,
+		Namespace:     cfg.Namespace,
+		Auditor:       auditor,
+		Freezes:       freezes,
+		Approvals:     approval.Rules{ScaleToZero: cfg.ApprovalScaleToZero, MaxChangePercent: cfg.ApprovalMaxChangePercent},
+		PDBMode:       cfg.PDBMode,
+		CatchUpWindow: cfg.ScheduleCatchUpWindow,
+	})
+}
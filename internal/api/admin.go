@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+type cacheEntryResponse struct {
+	Name            string             `json:"name"`
+	Replicas        int32              `json:"replicas"`
+	MinReplicas     *int32             `json:"minReplicas,omitempty"`
+	MaxReplicas     *int32             `json:"maxReplicas,omitempty"`
+	LastScale       *lastScaleResponse `json:"lastScale,omitempty"`
+	HPA             *hpaResponse       `json:"hpa,omitempty"`
+	PDB             *pdbResponse       `json:"pdb,omitempty"`
+	ResourceVersion string             `json:"resourceVersion"`
+	UpdatedAt       time.Time          `json:"updatedAt"`
+}
+
+type informerStatsResponse struct {
+	Namespace        string     `json:"namespace"`
+	CacheSize        int        `json:"cacheSize"`
+	Synced           bool       `json:"synced"`
+	Stale            bool       `json:"stale"`
+	ResourceVersion  string     `json:"resourceVersion"`
+	LastEvent        *time.Time `json:"lastEvent,omitempty"`
+	LastResync       *time.Time `json:"lastResync,omitempty"`
+	LastProgress     *time.Time `json:"lastProgress,omitempty"`
+	LastWatchError   string     `json:"lastWatchError,omitempty"`
+	LastWatchErrorAt *time.Time `json:"lastWatchErrorAt,omitempty"`
+}
+
+// newAdminMux serves debug endpoints. The admin listener is unauthenticated and opt-in.
+func (s *Server) newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/debug/cache", s.handleDebugCache)
+	mux.HandleFunc("/debug/informer", s.handleDebugInformer)
+	mux.HandleFunc("/debug/config", s.handleDebugConfig)
+	return mux
+}
+
+func (s *Server) inspector(w http.ResponseWriter) (kube.Inspector, bool) {
+	inspector, ok := s.store.(kube.Inspector)
+	if !ok {
+		http.Error(w, "store does not support inspection", http.StatusNotImplemented)
+	}
+	return inspector, ok
+}
+
+func (s *Server) handleDebugCache(w http.ResponseWriter, r *http.Request) {
+	inspector, ok := s.inspector(w)
+	if !ok {
+		return
+	}
+
+	snapshot := inspector.Snapshot()
+	out := make([]cacheEntryResponse, 0, len(snapshot))
+	for _, d := range snapshot {
+		out = append(out, cacheEntryResponse{
+			Name:            d.Name,
+			Replicas:        d.Replicas,
+			MinReplicas:     d.MinReplicas,
+			MaxReplicas:     d.MaxReplicas,
+			LastScale:       newLastScaleResponse(d.LastScale),
+			HPA:             newHPAResponse(d.HPA),
+			PDB:             newPDBResponse(d.PDB),
+			ResourceVersion: d.ResourceVersion,
+			UpdatedAt:       d.UpdatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deployments": out})
+}
+
+func (s *Server) handleDebugInformer(w http.ResponseWriter, r *http.Request) {
+	inspector, ok := s.inspector(w)
+	if !ok {
+		return
+	}
+
+	st := inspector.InformerStats()
+	writeJSON(w, http.StatusOK, informerStatsResponse{
+		Namespace:        st.Namespace,
+		CacheSize:        st.CacheSize,
+		Synced:           st.Synced,
+		Stale:            st.Stale,
+		ResourceVersion:  st.ResourceVersion,
+		LastEvent:        timePtr(st.LastEvent),
+		LastResync:       timePtr(st.LastResync),
+		LastProgress:     timePtr(st.LastProgress),
+		LastWatchError:   st.LastWatchError,
+		LastWatchErrorAt: timePtr(st.LastWatchErrorAt),
+	})
+}
+
+func (s *Server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.cfg.Redacted())
+}
+
+// timePtr returns nil for the zero time so it is omitted from JSON.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/logging"
+)
+
+// newAuditEvent returns the attempt record for change.Caller scaling name to replicas.
+func (s *Server) newAuditEvent(r *http.Request, name string, replicas int32, change kube.Change) audit.Event {
+	return audit.Event{
+		Stage:             audit.StageAttempt,
+		RequestID:         logging.RequestID(r.Context()),
+		Caller:            change.Caller,
+		SourceIP:          remoteIP(r),
+		Namespace:         s.cfg.Namespace,
+		Deployment:        name,
+		RequestedReplicas: replicas,
+		Reason:            change.Reason,
+		Ticket:            change.Ticket,
+	}
+}
+
+// auditAttempt records e and returns it to complete with auditResult. The returned error
+// is only non-nil when the attempt could not be recorded and either the audit log is
+// configured to fail closed or e is a break-glass override, which is never applied
+// unaudited.
+func (s *Server) auditAttempt(r *http.Request, e audit.Event) (audit.Event, error) {
+	if s.auditor == nil {
+		if e.BreakGlass {
+			return e, errors.New("no audit log configured")
+		}
+		return e, nil
+	}
+
+	// Previous replicas come from the cache, so they reflect what the caller could see.
+	if prev, ok, err := s.store.GetReplicas(r.Context(), e.Deployment); err == nil && ok {
+		e.PreviousReplicas = &prev
+	}
+
+	if err := s.auditor.Log(e); err != nil && (s.auditor.FailClosed() || e.BreakGlass) {
+		return e, err
+	}
+	return e, nil
+}
+
+// auditResult records the outcome of a write started with auditAttempt. The change has
+// already happened (or failed), so audit errors are only logged.
+func (s *Server) auditResult(e audit.Event, err error) {
+	if s.auditor == nil {
+		return
+	}
+
+	e.Stage = audit.StageResult
+	switch {
+	case err == nil:
+		e.Result = audit.ResultSuccess
+	case isRejection(err):
+		e.Result = audit.ResultRejected
+		e.Error = err.Error()
+	default:
+		e.Result = audit.ResultError
+		e.Error = err.Error()
+	}
+	_ = s.auditor.Log(e)
+}
+
+// auditRefusal records a write refused before it was attempted, with result
+// audit.ResultDenied or audit.ResultInvalid. Only a result record is written, since no
+// change was attempted.
+func (s *Server) auditRefusal(r *http.Request, result, reason string) {
+	if s.auditor == nil || isReadOnlyMethod(r.Method) {
+		return
+	}
+	_ = s.auditor.Log(audit.Event{
+		Stage:      audit.StageResult,
+		RequestID:  logging.RequestID(r.Context()),
+		Caller:     clientIdentity(r),
+		SourceIP:   remoteIP(r),
+		Namespace:  s.cfg.Namespace,
+		Deployment: deploymentFromPath(r.URL.Path),
+		Path:       r.URL.Path,
+		Result:     result,
+		Error:      reason,
+	})
+}
+
+// rejectInvalid audits a malformed write request and responds with 400.
+func (s *Server) rejectInvalid(w http.ResponseWriter, r *http.Request, msg string) {
+	s.auditRefusal(r, audit.ResultInvalid, msg)
+	http.Error(w, msg, http.StatusBadRequest)
+}
+
+// deploymentFromPath returns the deployment named by a /api/v1/deployments/{name}/...
+// path, or "".
+func deploymentFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/v1/deployments/")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, "/")
+	return name
+}
+
+// isRejection reports whether err refused a write by policy rather than failing it.
+func isRejection(err error) bool {
+	var (
+		frozenErr   *freeze.FrozenError
+		approvalErr *approval.RequiredError
+	)
+	return kube.IsRejection(err) ||
+		errors.As(err, &frozenErr) ||
+		errors.As(err, &approvalErr)
+}
+
+// remoteIP returns the host part of r.RemoteAddr.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// role is the access level granted to an API client.
+type role int
+
+const (
+	roleReadWrite role = iota
+	roleReadOnly
+)
+
+// caSet identifies CA certificates by their raw DER encoding.
+type caSet map[string]struct{}
+
+func newCASet(certs []*x509.Certificate) caSet {
+	set := make(caSet, len(certs))
+	for _, c := range certs {
+		set[string(c.Raw)] = struct{}{}
+	}
+	return set
+}
+
+func (s caSet) contains(c *x509.Certificate) bool {
+	_, ok := s[string(c.Raw)]
+	return ok
+}
+
+// clientRole determines which trust domain verified the peer certificate. A client is
+// read-write only when a verified chain ends in the read-write CA bundle; clients whose
+// identity is unknown (verify-if-given without a cert, or a chain anchored in neither
+// bundle) are read-only. With TLS disabled there is no authentication at all and every
+// client is read-write.
+func (s *Server) clientRole(r *http.Request) role {
+	if !s.cfg.TLSEnabled {
+		return roleReadWrite
+	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return roleReadOnly
+	}
+	if len(s.readOnlyCAs) == 0 {
+		return roleReadWrite
+	}
+
+	for _, chain := range r.TLS.VerifiedChains {
+		if s.chainRole(chain) == roleReadWrite {
+			return roleReadWrite
+		}
+	}
+	return roleReadOnly
+}
+
+// chainRole returns the role of the bundle that anchors chain. Bundles may contain
+// intermediates, so the chain is walked from the root towards the leaf and the first
+// certificate found in either bundle decides; a chain in neither is read-only.
+func (s *Server) chainRole(chain []*x509.Certificate) role {
+	for i := len(chain) - 1; i >= 0; i-- {
+		switch {
+		case s.readWriteCAs.contains(chain[i]):
+			return roleReadWrite
+		case s.readOnlyCAs.contains(chain[i]):
+			return roleReadOnly
+		}
+	}
+	return roleReadOnly
+}
+
+// isReadOnlyMethod reports whether the method never modifies state.
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// clientIdentity returns a stable identifier for the caller: the verified client
+// certificate's identity (see certIdentity), falling back to the remote IP for requests
+// without one.
+func clientIdentity(r *http.Request) string {
+	if id, ok := certIdentity(r); ok {
+		return id
+	}
+	return remoteIP(r)
+}
+
+// certIdentity returns the verified client certificate's common name, or its full
+// subject when the CN is empty. Unlike a remote IP, it cannot be shared by unrelated
+// clients, so decisions between identities (such as approvals) require it.
+func certIdentity(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return "", false
+	}
+	subject := r.TLS.PeerCertificates[0].Subject
+	if subject.CommonName != "" {
+		return subject.CommonName, true
+	}
+	return subject.String(), true
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+type budgetResponse struct {
+	Namespace   string `json:"namespace"`
+	Deployments int    `json:"deployments"`
+	Used        int64  `json:"used"`
+	// Budget and Remaining are omitted when no budget is configured.
+	Budget    *int64 `json:"budget,omitempty"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+// checkBudget returns a *kube.BudgetError when scaling name to replicas would take the
+// sum of cached replicas over cfg.ReplicaBudget. It lets requests needing approval be
+// refused before they are held; the store enforces the budget under a lock when the
+// change is applied. Scale-downs are always allowed, so a namespace that is already over
+// budget can recover.
+func (s *Server) checkBudget(ctx context.Context, name string, replicas int32) error {
+	if s.cfg.ReplicaBudget <= 0 {
+		return nil
+	}
+	counter, ok := s.store.(kube.ReplicaCounter)
+	if !ok {
+		return nil
+	}
+
+	current, _, err := s.store.GetReplicas(ctx, name)
+	if err != nil {
+		return err
+	}
+	if replicas <= current {
+		return nil
+	}
+
+	used, _ := counter.TotalReplicas()
+	total := used - int64(current) + int64(replicas)
+	if budget := int64(s.cfg.ReplicaBudget); total > budget {
+		return &kube.BudgetError{Budget: budget, Used: used, Requested: total}
+	}
+	return nil
+}
+
+func (s *Server) handleGetBudget(w http.ResponseWriter, r *http.Request) {
+	_, span := startSpan(r, "api.handleGetBudget")
+	defer span.End()
+
+	counter, ok := s.store.(kube.ReplicaCounter)
+	if !ok {
+		http.Error(w, "replica totals not available", http.StatusNotImplemented)
+		return
+	}
+
+	used, deployments := counter.TotalReplicas()
+	resp := budgetResponse{Namespace: s.cfg.Namespace, Deployments: deployments, Used: used}
+	if s.cfg.ReplicaBudget > 0 {
+		budget := int64(s.cfg.ReplicaBudget)
+		remaining := max(budget-used, 0)
+		resp.Budget = &budget
+		resp.Remaining = &remaining
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
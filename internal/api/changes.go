@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type listChangesResponse struct {
+	Changes []approval.Change `json:"changes"`
+}
+
+type rejectChangeRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// approvalUnknownError refuses a write when the current replica count, and so whether
+// the change needs approval, cannot be read.
+type approvalUnknownError struct {
+	err error
+}
+
+func (e *approvalUnknownError) Error() string {
+	return fmt.Sprintf("cannot determine whether the change requires approval: %v", e.err)
+}
+
+func (e *approvalUnknownError) Unwrap() error { return e.err }
+
+// submitChange holds a write for approval and responds 202 with the pending change.
+func (s *Server) submitChange(w http.ResponseWriter, r *http.Request, event audit.Event, c approval.Change) {
+	event, err := s.auditAttempt(r, event)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"error": "audit log unavailable",
+		})
+		return
+	}
+
+	c = s.approvals.Submit(c)
+
+	event.Stage = audit.StageResult
+	event.Result = audit.ResultPending
+	event.ChangeID = c.ID
+	_ = s.auditor.Log(event)
+
+	writeJSON(w, http.StatusAccepted, c)
+}
+
+// denyUnidentified refuses to request or decide an approval without a client
+// certificate identity: remote IPs can be shared, which would let one client approve
+// its own change from another host.
+func (s *Server) denyUnidentified(w http.ResponseWriter, r *http.Request) {
+	const msg = "a client certificate identity is required for changes that need approval"
+	s.auditRefusal(r, audit.ResultDenied, msg)
+	writeJSON(w, http.StatusForbidden, map[string]any{"error": msg})
+}
+
+// routeChanges serves /api/v1/changes, /api/v1/changes/{id} and the {id}:approve and
+// {id}:reject actions. rest is the path after /changes/.
+func (s *Server) routeChanges(w http.ResponseWriter, r *http.Request, rest string) {
+	if s.approvals == nil {
+		http.Error(w, "approvals not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	id, action, _ := strings.Cut(rest, ":")
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, listChangesResponse{Changes: s.approvals.List()})
+	case strings.Contains(id, "/"):
+		http.NotFound(w, r)
+	case action == "" && r.Method == http.MethodGet:
+		c, ok := s.approvals.Get(id)
+		if !ok {
+			http.Error(w, "change not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+	case action == "approve" && r.Method == http.MethodPost:
+		s.handleApproveChange(w, r, id)
+	case action == "reject" && r.Method == http.MethodPost:
+		s.handleRejectChange(w, r, id)
+	case rest == "" || action == "" || action == "approve" || action == "reject":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleApproveChange(w http.ResponseWriter, r *http.Request, id string) {
+	r, span := startSpan(r, "api.handleApproveChange", attribute.String("change.id", id))
+	defer span.End()
+
+	identity, ok := certIdentity(r)
+	if !ok {
+		s.denyUnidentified(w, r)
+		return
+	}
+	c, ok := s.approvals.Get(id)
+	if !ok {
+		http.Error(w, "change not found", http.StatusNotFound)
+		return
+	}
+
+	// Freezes, the budget and PDBs are checked again at approval time; a refused change
+	// stays pending so it can be approved once the window ends or capacity frees up. The
+	// freeze applies to the requester, whose break-glass still overrides it.
+	var window freeze.Window
+	var frozen bool
+	if c.State == approval.StatePending && c.RequestedBy != identity {
+		var err error
+		window, frozen, err = s.activeFreeze(r.Context(), c.Deployment, c.RequestedBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if frozen && !c.BreakGlass {
+			writeScaleError(w, &freeze.FrozenError{Window: window.Name})
+			return
+		}
+		if err := s.checkBudget(r.Context(), c.Deployment, c.Replicas); err != nil {
+			writeScaleError(w, err)
+			return
+		}
+		if _, err := s.checkPDB(r.Context(), c.Deployment, c.Replicas); err != nil {
+			writeScaleError(w, err)
+			return
+		}
+	}
+
+	c, err := s.approvals.Approve(id, identity)
+	if err != nil {
+		writeDecisionError(w, err)
+		return
+	}
+
+	change := kube.Change{Caller: c.RequestedBy, Reason: c.Reason, Ticket: c.Ticket}
+	event := s.newAuditEvent(r, c.Deployment, c.Replicas, change)
+	event.ChangeID = c.ID
+	event.DecidedBy = identity
+	event.HPAOverride = c.HPAOverride
+	if frozen {
+		event.FreezeWindow = window.Name
+		event.BreakGlass = true
+		slog.WarnContext(r.Context(), "freeze window overridden with break-glass",
+			"window", window.Name, "caller", c.RequestedBy, "approver", identity, "deployment", c.Deployment, "reason", c.Reason)
+	}
+	event, err = s.auditAttempt(r, event)
+	if err != nil {
+		s.approvals.Complete(id, errors.New("audit log unavailable"))
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"error": "audit log unavailable",
+		})
+		return
+	}
+
+	ctx := kube.WithChange(r.Context(), change)
+	if c.HPAOverride {
+		ctx = kube.WithHPAOverride(ctx)
+	}
+	err = s.store.SetReplicas(ctx, c.Deployment, c.Replicas)
+	s.auditResult(event, err)
+	c = s.approvals.Complete(id, err)
+	if err != nil {
+		writeScaleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) handleRejectChange(w http.ResponseWriter, r *http.Request, id string) {
+	r, span := startSpan(r, "api.handleRejectChange", attribute.String("change.id", id))
+	defer span.End()
+
+	// The body is optional; an empty one rejects without a reason.
+	var req rejectChangeRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MiB
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && err != io.EOF {
+		s.rejectInvalid(w, r, "invalid json body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > maxReasonLength {
+		s.rejectInvalid(w, r, fmt.Sprintf("reason must be at most %d bytes", maxReasonLength))
+		return
+	}
+
+	identity, ok := certIdentity(r)
+	if !ok {
+		s.denyUnidentified(w, r)
+		return
+	}
+	c, err := s.approvals.Reject(id, identity, req.Reason)
+	if err != nil {
+		writeDecisionError(w, err)
+		return
+	}
+
+	event := s.newAuditEvent(r, c.Deployment, c.Replicas, kube.Change{Caller: c.RequestedBy, Reason: c.Reason, Ticket: c.Ticket})
+	event.Stage = audit.StageResult
+	event.Result = audit.ResultRejected
+	event.ChangeID = c.ID
+	event.DecidedBy = identity
+	event.Error = "rejected by approver"
+	if req.Reason != "" {
+		event.Error += ": " + req.Reason
+	}
+	_ = s.auditor.Log(event)
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// writeDecisionError maps an approval.Queue decision error to a response.
+func writeDecisionError(w http.ResponseWriter, err error) {
+	var notPending *approval.NotPendingError
+	switch {
+	case errors.Is(err, approval.ErrNotFound):
+		http.Error(w, "change not found", http.StatusNotFound)
+	case errors.Is(err, approval.ErrSelfApproval):
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": err.Error()})
+	case errors.As(err, &notPending):
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error": err.Error(),
+			"state": notPending.State,
+		})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
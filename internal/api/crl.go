@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// crlChecker rejects client certificates listed in one or more certificate revocation lists.
+// CRLs are loaded from disk and can be reloaded without restarting the server.
+type crlChecker struct {
+	files   []string
+	issuers []*x509.Certificate
+
+	mu       sync.RWMutex
+	revoked  map[string]struct{} // revocationKey(issuer, serial)
+	modTimes map[string]time.Time
+}
+
+// newCRLChecker loads the given CRL files. Every CRL must be signed by one of issuers.
+func newCRLChecker(files []string, issuers []*x509.Certificate) (*crlChecker, error) {
+	c := &crlChecker{
+		files:   files,
+		issuers: issuers,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload re-reads all CRL files. On error the previously loaded revocations are kept.
+func (c *crlChecker) reload() error {
+	revoked := make(map[string]struct{})
+	modTimes := make(map[string]time.Time, len(c.files))
+
+	for _, file := range c.files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("stat crl %s: %w", file, err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read crl %s: %w", file, err)
+		}
+		crls, err := parseCRLs(data)
+		if err != nil {
+			return fmt.Errorf("parse crl %s: %w", file, err)
+		}
+		for _, crl := range crls {
+			if err := c.checkSignature(crl); err != nil {
+				return fmt.Errorf("crl %s: %w", file, err)
+			}
+			if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+				slog.Warn("crl is past its next update time; using it anyway", "file", file, "next_update", crl.NextUpdate)
+			}
+			for _, entry := range crl.RevokedCertificateEntries {
+				revoked[revocationKey(crl.RawIssuer, entry.SerialNumber.String())] = struct{}{}
+			}
+		}
+		modTimes[file] = fi.ModTime()
+	}
+
+	c.mu.Lock()
+	c.revoked = revoked
+	c.modTimes = modTimes
+	c.mu.Unlock()
+
+	slog.Info("loaded client crls", "files", len(c.files), "revoked", len(revoked))
+	return nil
+}
+
+// reloadIfChanged reloads the CRLs when any file's modification time has changed.
+func (c *crlChecker) reloadIfChanged() {
+	c.mu.RLock()
+	changed := false
+	for _, file := range c.files {
+		fi, err := os.Stat(file)
+		if err != nil || !fi.ModTime().Equal(c.modTimes[file]) {
+			changed = true
+			break
+		}
+	}
+	c.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+	if err := c.reload(); err != nil {
+		slog.Error("reload crl failed; keeping previous revocation list", "error", err)
+	}
+}
+
+// watch polls CRL files for changes until stop is closed.
+func (c *crlChecker) watch(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			c.reloadIfChanged()
+		}
+	}
+}
+
+// verifyPeerCertificate is installed as tls.Config.VerifyPeerCertificate. It runs after
+// standard chain verification, so only verified chains need to be inspected.
+func (c *crlChecker) verifyPeerCertificate(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, chain := range verifiedChains {
+		// The last element is the trusted root; CRLs cover the certificates it issued.
+		for i := 0; i < len(chain)-1; i++ {
+			cert := chain[i]
+			serial := cert.SerialNumber.String()
+			if _, ok := c.revoked[revocationKey(cert.RawIssuer, serial)]; ok {
+				slog.Warn("rejecting revoked client certificate", "serial", serial, "subject", cert.Subject.String())
+				return fmt.Errorf("client certificate serial %s has been revoked", serial)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *crlChecker) checkSignature(crl *x509.RevocationList) error {
+	for _, issuer := range c.issuers {
+		if !bytes.Equal(issuer.RawSubject, crl.RawIssuer) {
+			continue
+		}
+		if err := crl.CheckSignatureFrom(issuer); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("not signed by a trusted client CA")
+}
+
+// parseCRLs accepts either PEM (one or more "X509 CRL" blocks) or a single DER encoded CRL.
+func parseCRLs(data []byte) ([]*x509.RevocationList, error) {
+	var out []*x509.RevocationList
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, crl)
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.RevocationList{crl}, nil
+}
+
+func revocationKey(rawIssuer []byte, serial string) string {
+	return string(rawIssuer) + "/" + serial
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+// activeFreeze returns the freeze window that currently refuses identity scaling name.
+func (s *Server) activeFreeze(ctx context.Context, name, identity string) (freeze.Window, bool, error) {
+	if s.freezes == nil {
+		return freeze.Window{}, false, nil
+	}
+
+	// Selector windows need the deployment's labels; without them only namespace-wide
+	// windows can match.
+	var lbls map[string]string
+	if describer, ok := s.store.(kube.Describer); ok {
+		d, _, err := describer.DescribeDeployment(ctx, name)
+		if err != nil {
+			return freeze.Window{}, false, err
+		}
+		lbls = d.Labels
+	}
+
+	w, frozen := s.freezes.Check(s.cfg.Namespace, lbls, identity, time.Now())
+	return w, frozen, nil
+}
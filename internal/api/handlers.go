@@ -1,16 +1,27 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/history"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"go.opentelemetry.io/otel/attribute"
+	appsv1 "k8s.io/api/apps/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type listDeploymentsResponse struct {
@@ -18,46 +29,104 @@ type listDeploymentsResponse struct {
 }
 
 type getReplicasResponse struct {
-	Name     string `json:"name"`
-	Replicas int32  `json:"replicas"`
+	Name        string             `json:"name"`
+	Replicas    int32              `json:"replicas"`
+	MinReplicas *int32             `json:"minReplicas,omitempty"`
+	MaxReplicas *int32             `json:"maxReplicas,omitempty"`
+	LastScale   *lastScaleResponse `json:"lastScale,omitempty"`
+	HPA         *hpaResponse       `json:"hpa,omitempty"`
+	PDB         *pdbResponse       `json:"pdb,omitempty"`
+}
+
+type hpaResponse struct {
+	Name            string               `json:"name"`
+	MinReplicas     int32                `json:"minReplicas"`
+	MaxReplicas     int32                `json:"maxReplicas"`
+	CurrentReplicas int32                `json:"currentReplicas"`
+	DesiredReplicas int32                `json:"desiredReplicas"`
+	Override        *hpaOverrideResponse `json:"override,omitempty"`
+}
+
+type pdbResponse struct {
+	Name               string              `json:"name"`
+	MinAvailable       *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable     *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	ExpectedPods       int32               `json:"expectedPods"`
+	CurrentHealthy     int32               `json:"currentHealthy"`
+	DesiredHealthy     int32               `json:"desiredHealthy"`
+	DisruptionsAllowed int32               `json:"disruptionsAllowed"`
+}
+
+type hpaOverrideResponse struct {
+	Until               time.Time `json:"until"`
+	OriginalMinReplicas *int32    `json:"originalMinReplicas,omitempty"`
+	OriginalMaxReplicas int32     `json:"originalMaxReplicas"`
+}
+
+type lastScaleResponse struct {
+	By               string     `json:"by,omitempty"`
+	At               *time.Time `json:"at,omitempty"`
+	Reason           string     `json:"reason,omitempty"`
+	Ticket           string     `json:"ticket,omitempty"`
+	PreviousReplicas *int32     `json:"previousReplicas,omitempty"`
+}
+
+type historyResponse struct {
+	Name    string          `json:"name"`
+	Entries []history.Entry `json:"entries"`
+}
+
+type schedulesResponse struct {
+	Schedules []scheduleResponse `json:"schedules"`
+}
+
+type scheduleResponse struct {
+	Name       string     `json:"name"`
+	Deployment string     `json:"deployment,omitempty"`
+	Selector   string     `json:"selector,omitempty"`
+	Replicas   int32      `json:"replicas"`
+	Schedule   string     `json:"schedule"`
+	Timezone   string     `json:"timezone,omitempty"`
+	NextRun    *time.Time `json:"nextRun,omitempty"`
+	LastRun    *time.Time `json:"lastRun,omitempty"`
+	LastResult string     `json:"lastResult,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
 }
 
 type setReplicasRequest struct {
 	Replicas *int32 `json:"replicas"`
+	// Reason and Ticket are optional and recorded on the Deployment as annotations.
+	Reason string `json:"reason,omitempty"`
+	Ticket string `json:"ticket,omitempty"`
+	// BreakGlass overrides an active freeze window. It requires a reason.
+	BreakGlass bool `json:"breakGlass,omitempty"`
+	// HPAOverride scales a Deployment managed by an HPA by temporarily pinning the HPA.
+	HPAOverride bool `json:"hpaOverride,omitempty"`
 }
 
+// cacheAgeHeader carries the cache age in whole seconds on API responses.
+const cacheAgeHeader = "X-Cache-Age"
+
+// Limits on caller-supplied attribution, which ends up in annotations and Events.
+const (
+	maxReasonLength = 512
+	maxTicketLength = 128
+)
+
 type statusResponse struct {
 	Status string `json:"status"`
+	// Warnings describe risks of an applied change, such as a PDB left blocking evictions.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
 }
 
-func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
-	if s.store == nil {
-		http.Error(w, "store not configured", http.StatusServiceUnavailable)
-		return
-	}
-	if !s.store.Ready() {
-		http.Error(w, "cache not synced", http.StatusServiceUnavailable)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
-
-	// Ping is optional so unit tests can provide a lightweight Store implementation.
-	if pinger, ok := s.store.(kube.Pinger); ok {
-		if err := pinger.Ping(ctx); err != nil {
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-			return
-		}
-	}
-	writeJSON(w, http.StatusOK, map[string]any{"status": "ready"})
-}
-
 func (s *Server) handleListDeployments(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "api.handleListDeployments")
+	defer span.End()
+
 	deps, err := s.store.ListDeployments(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -69,6 +138,32 @@ func (s *Server) handleListDeployments(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetReplicas(w http.ResponseWriter, r *http.Request, name string) {
+	r, span := startSpan(r, "api.handleGetReplicas", attribute.String("k8s.deployment.name", name))
+	defer span.End()
+
+	// Describer is optional; fall back to the plain replica count.
+	if describer, ok := s.store.(kube.Describer); ok {
+		d, ok, err := describer.DescribeDeployment(r.Context(), name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "deployment not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, getReplicasResponse{
+			Name:        name,
+			Replicas:    d.Replicas,
+			MinReplicas: d.MinReplicas,
+			MaxReplicas: d.MaxReplicas,
+			LastScale:   newLastScaleResponse(d.LastScale),
+			HPA:         newHPAResponse(d.HPA),
+			PDB:         newPDBResponse(d.PDB),
+		})
+		return
+	}
+
 	rep, ok, err := s.store.GetReplicas(r.Context(), name)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -81,7 +176,59 @@ func (s *Server) handleGetReplicas(w http.ResponseWriter, r *http.Request, name
 	writeJSON(w, http.StatusOK, getReplicasResponse{Name: name, Replicas: rep})
 }
 
+func newLastScaleResponse(ls *kube.LastScale) *lastScaleResponse {
+	if ls == nil {
+		return nil
+	}
+	return &lastScaleResponse{
+		By:               ls.By,
+		Reason:           ls.Reason,
+		Ticket:           ls.Ticket,
+		PreviousReplicas: ls.PreviousReplicas,
+		At:               timePtr(ls.At),
+	}
+}
+
+func newHPAResponse(h *kube.HPA) *hpaResponse {
+	if h == nil {
+		return nil
+	}
+	resp := &hpaResponse{
+		Name:            h.Name,
+		MinReplicas:     h.MinReplicas,
+		MaxReplicas:     h.MaxReplicas,
+		CurrentReplicas: h.CurrentReplicas,
+		DesiredReplicas: h.DesiredReplicas,
+	}
+	if o := h.Override; o != nil {
+		resp.Override = &hpaOverrideResponse{
+			Until:               o.Until,
+			OriginalMinReplicas: o.OriginalMinReplicas,
+			OriginalMaxReplicas: o.OriginalMaxReplicas,
+		}
+	}
+	return resp
+}
+
+func newPDBResponse(p *kube.PDB) *pdbResponse {
+	if p == nil {
+		return nil
+	}
+	return &pdbResponse{
+		Name:               p.Name,
+		MinAvailable:       p.MinAvailable,
+		MaxUnavailable:     p.MaxUnavailable,
+		ExpectedPods:       p.ExpectedPods,
+		CurrentHealthy:     p.CurrentHealthy,
+		DesiredHealthy:     p.DesiredHealthy,
+		DisruptionsAllowed: p.DisruptionsAllowed,
+	}
+}
+
 func (s *Server) handleSetReplicas(w http.ResponseWriter, r *http.Request, name string) {
+	r, span := startSpan(r, "api.handleSetReplicas", attribute.String("k8s.deployment.name", name))
+	defer span.End()
+
 	var req setReplicasRequest
 
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MiB
@@ -89,35 +236,305 @@ func (s *Server) handleSetReplicas(w http.ResponseWriter, r *http.Request, name
 	dec.DisallowUnknownFields()
 
 	if err := dec.Decode(&req); err != nil {
-		http.Error(w, "invalid json body", http.StatusBadRequest)
+		s.rejectInvalid(w, r, "invalid json body")
 		return
 	}
 	// Ensure there's no trailing junk after the first JSON object.
 	if err := dec.Decode(&struct{}{}); err != io.EOF {
-		http.Error(w, "invalid json body", http.StatusBadRequest)
+		s.rejectInvalid(w, r, "invalid json body")
 		return
 	}
 
 	if req.Replicas == nil {
-		http.Error(w, "replicas is required", http.StatusBadRequest)
+		s.rejectInvalid(w, r, "replicas is required")
 		return
 	}
 
 	if *req.Replicas < 0 {
-		http.Error(w, "replicas must be >= 0", http.StatusBadRequest)
+		s.rejectInvalid(w, r, "replicas must be >= 0")
 		return
 	}
 
-	if err := s.store.SetReplicas(r.Context(), name, *req.Replicas); err != nil {
-		if apierrors.IsNotFound(err) {
-			http.Error(w, "deployment not found", http.StatusNotFound)
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Ticket = strings.TrimSpace(req.Ticket)
+	if len(req.Reason) > maxReasonLength {
+		s.rejectInvalid(w, r, fmt.Sprintf("reason must be at most %d bytes", maxReasonLength))
+		return
+	}
+	if len(req.Ticket) > maxTicketLength {
+		s.rejectInvalid(w, r, fmt.Sprintf("ticket must be at most %d bytes", maxTicketLength))
+		return
+	}
+
+	if req.BreakGlass && req.Reason == "" {
+		s.rejectInvalid(w, r, "reason is required for breakGlass")
+		return
+	}
+
+	change := kube.Change{Caller: clientIdentity(r), Reason: req.Reason, Ticket: req.Ticket}
+	event := s.newAuditEvent(r, name, *req.Replicas, change)
+	event.HPAOverride = req.HPAOverride
+
+	window, frozen, err := s.activeFreeze(r.Context(), name, change.Caller)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if frozen {
+		event.FreezeWindow = window.Name
+		if !req.BreakGlass {
+			s.rejectWrite(w, r, event, &freeze.FrozenError{Window: window.Name})
+			return
+		}
+		event.BreakGlass = true
+		slog.WarnContext(r.Context(), "freeze window overridden with break-glass",
+			"window", window.Name, "caller", change.Caller, "deployment", name, "reason", req.Reason)
+	}
+
+	if err := s.checkBudget(r.Context(), name, *req.Replicas); err != nil {
+		s.rejectWrite(w, r, event, err)
+		return
+	}
+
+	var warnings []string
+	warning, err := s.checkPDB(r.Context(), name, *req.Replicas)
+	if err != nil {
+		s.rejectWrite(w, r, event, err)
+		return
+	}
+	if warning != "" {
+		warnings = append(warnings, warning)
+		slog.WarnContext(r.Context(), "scale-down blocks poddisruptionbudget evictions", "deployment", name, "replicas", *req.Replicas, "caller", change.Caller, "warning", warning)
+	}
+
+	// Risky changes are held until a second identity approves them. Without the current
+	// count there is no telling whether a change is risky, so the gate fails closed.
+	if s.approvals != nil {
+		prev, ok, err := s.store.GetReplicas(r.Context(), name)
+		if err != nil {
+			s.rejectWrite(w, r, event, &approvalUnknownError{err: err})
+			return
+		}
+		if !ok {
+			s.rejectWrite(w, r, event, apierrors.NewNotFound(appsv1.Resource("deployments"), name))
+			return
+		}
+		if rule, required := s.approvalRules.Requires(prev, *req.Replicas); required {
+			if _, ok := certIdentity(r); !ok {
+				s.denyUnidentified(w, r)
+				return
+			}
+			s.submitChange(w, r, event, approval.Change{
+				Deployment:       name,
+				Replicas:         *req.Replicas,
+				PreviousReplicas: prev,
+				Rule:             rule,
+				RequestedBy:      change.Caller,
+				Reason:           change.Reason,
+				Ticket:           change.Ticket,
+				HPAOverride:      req.HPAOverride,
+				BreakGlass:       req.BreakGlass,
+			})
+			return
+		}
+	}
+
+	event, err = s.auditAttempt(r, event)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"error": "audit log unavailable",
+		})
+		return
+	}
+
+	ctx := kube.WithChange(r.Context(), change)
+	if req.HPAOverride {
+		ctx = kube.WithHPAOverride(ctx)
+	}
+	err = s.store.SetReplicas(ctx, name, *req.Replicas)
+	s.auditResult(event, err)
+	if err != nil {
+		writeScaleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{Status: "updated", Warnings: warnings})
+}
+
+// rejectWrite audits a write refused before reaching the store and responds with err.
+func (s *Server) rejectWrite(w http.ResponseWriter, r *http.Request, event audit.Event, err error) {
+	event, _ = s.auditAttempt(r, event)
+	s.auditResult(event, err)
+	writeScaleError(w, err)
+}
+
+// writeScaleError maps a SetReplicas error, or a policy rejection, to a response.
+func writeScaleError(w http.ResponseWriter, err error) {
+	if apierrors.IsNotFound(err) {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
+	}
+	var boundsErr *kube.BoundsError
+	if errors.As(err, &boundsErr) && boundsErr.Invalid != "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":   boundsErr.Error(),
+			"invalid": boundsErr.Invalid,
+		})
+		return
+	}
+	if errors.As(err, &boundsErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error": boundsErr.Error(),
+			"bound": boundsErr.Bound,
+			"limit": boundsErr.Limit,
+		})
+		return
+	}
+	var unknownErr *approvalUnknownError
+	if errors.As(err, &unknownErr) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"error": "cannot determine whether the change requires approval",
+		})
+		return
+	}
+	var frozenErr *freeze.FrozenError
+	if errors.As(err, &frozenErr) {
+		writeJSON(w, http.StatusLocked, map[string]any{
+			"error":  frozenErr.Error(),
+			"window": frozenErr.Window,
+		})
+		return
+	}
+	var budgetErr *kube.BudgetError
+	if errors.As(err, &budgetErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":     budgetErr.Error(),
+			"budget":    budgetErr.Budget,
+			"used":      budgetErr.Used,
+			"remaining": max(budgetErr.Budget-budgetErr.Used, 0),
+		})
+		return
+	}
+	var quotaErr *kube.QuotaError
+	if errors.As(err, &quotaErr) {
+		violations := make([]map[string]any, 0, len(quotaErr.Violations))
+		for _, v := range quotaErr.Violations {
+			violations = append(violations, map[string]any{
+				"resource":  v.Resource,
+				"hard":      v.Hard.String(),
+				"used":      v.Used.String(),
+				"requested": v.Requested.String(),
+			})
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":      quotaErr.Error(),
+			"quota":      quotaErr.Quota,
+			"violations": violations,
+		})
+		return
+	}
+	var stepErr *kube.StepError
+	if errors.As(err, &stepErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":   stepErr.Error(),
+			"limit":   stepErr.Limit,
+			"percent": stepErr.Percent,
+		})
+		return
+	}
+	var pdbErr *kube.PDBError
+	if errors.As(err, &pdbErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":        pdbErr.Error(),
+			"pdb":          newPDBResponse(&pdbErr.PDB),
+			"expectedPods": pdbErr.ExpectedPods,
+		})
+		return
+	}
+	var hpaErr *kube.HPAConflictError
+	if errors.As(err, &hpaErr) {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error": hpaErr.Error(),
+			"hpa":   newHPAResponse(&hpaErr.HPA),
+		})
+		return
+	}
+	var cooldownErr *kube.CooldownError
+	if errors.As(err, &cooldownErr) {
+		secs := int(math.Ceil(cooldownErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{
+			"error":             cooldownErr.Error(),
+			"retryAfterSeconds": secs,
+			"nextAllowedAt":     cooldownErr.NextAllowed.UTC(),
+		})
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (s *Server) handleGetHistory(w http.ResponseWriter, r *http.Request, name string) {
+	r, span := startSpan(r, "api.handleGetHistory", attribute.String("k8s.deployment.name", name))
+	defer span.End()
+
+	if s.history == nil {
+		http.Error(w, "history not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	var since, until time.Time
+	for param, dst := range map[string]*time.Time{"since": &since, "until": &until} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, param+" must be an RFC 3339 timestamp", http.StatusBadRequest)
 			return
 		}
+		*dst = t
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		http.Error(w, "until must not be before since", http.StatusBadRequest)
+		return
+	}
+
+	// History is dropped with the cache entry, and unmanaged deployments must look
+	// exactly like missing ones.
+	if _, ok, err := s.store.GetReplicas(r.Context(), name); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
+	} else if !ok {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
 	}
 
-	writeJSON(w, http.StatusOK, statusResponse{Status: "updated"})
+	writeJSON(w, http.StatusOK, historyResponse{Name: name, Entries: s.history.List(name, since, until)})
+}
+
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	_, span := startSpan(r, "api.handleListSchedules")
+	defer span.End()
+
+	resp := schedulesResponse{Schedules: []scheduleResponse{}}
+	if s.scheduler != nil {
+		for _, st := range s.scheduler.Status() {
+			resp.Schedules = append(resp.Schedules, scheduleResponse{
+				Name:       st.Name,
+				Deployment: st.Deployment,
+				Selector:   st.Selector,
+				Replicas:   st.Replicas,
+				Schedule:   st.Schedule,
+				Timezone:   st.Timezone,
+				NextRun:    timePtr(st.NextRun),
+				LastRun:    timePtr(st.LastRun),
+				LastResult: st.LastResult,
+				LastError:  st.LastError,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) routeAPIv1(w http.ResponseWriter, r *http.Request) {
@@ -133,6 +550,23 @@ func (s *Server) routeAPIv1(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reads are served from the cache; tell clients how fresh it is.
+	if ager, ok := s.store.(kube.CacheAger); ok {
+		w.Header().Set(cacheAgeHeader, strconv.Itoa(int(ager.CacheAge().Seconds())))
+	}
+
+	// Clients from the read-only trust domain, or without a certificate, may never
+	// modify state.
+	if !isReadOnlyMethod(r.Method) && s.clientRole(r) == roleReadOnly {
+		msg := "client certificate is only authorized for read access"
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			msg = "a verified client certificate is required for write access"
+		}
+		s.auditRefusal(r, audit.ResultDenied, msg)
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": msg})
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
 	if path == "/deployments" || path == "/deployments/" {
 		if r.Method != http.MethodGet {
@@ -142,6 +576,31 @@ func (s *Server) routeAPIv1(w http.ResponseWriter, r *http.Request) {
 		s.handleListDeployments(w, r)
 		return
 	}
+	if path == "/changes" || path == "/changes/" || strings.HasPrefix(path, "/changes/") {
+		s.routeChanges(w, r, strings.Trim(strings.TrimPrefix(path, "/changes"), "/"))
+		return
+	}
+	if path == "/budget" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleGetBudget(w, r)
+		return
+	}
+	if path == "/namespace" || strings.HasPrefix(path, "/namespace:") {
+		_, action, _ := strings.Cut(path, ":")
+		s.routeNamespace(w, r, action)
+		return
+	}
+	if path == "/schedules" || path == "/schedules/" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleListSchedules(w, r)
+		return
+	}
 
 	const prefix = "/deployments/"
 	if !strings.HasPrefix(path, prefix) {
@@ -150,23 +609,22 @@ func (s *Server) routeAPIv1(w http.ResponseWriter, r *http.Request) {
 	}
 	rest := strings.TrimPrefix(path, prefix)
 	parts := strings.Split(strings.Trim(rest, "/"), "/")
-	if len(parts) != 2 || parts[1] != "replicas" {
+	if len(parts) != 2 || parts[0] == "" {
 		http.NotFound(w, r)
 		return
 	}
-
 	name := parts[0]
-	if name == "" {
-		http.NotFound(w, r)
-		return
-	}
 
-	switch r.Method {
-	case http.MethodGet:
+	switch {
+	case parts[1] == "replicas" && r.Method == http.MethodGet:
 		s.handleGetReplicas(w, r, name)
-	case http.MethodPost:
+	case parts[1] == "replicas" && r.Method == http.MethodPost:
 		s.handleSetReplicas(w, r, name)
-	default:
+	case parts[1] == "history" && r.Method == http.MethodGet:
+		s.handleGetHistory(w, r, name)
+	case parts[1] == "replicas" || parts[1] == "history":
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
 	}
 }
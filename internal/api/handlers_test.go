@@ -1,20 +1,41 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/config"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/history"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/metrics"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/scheduler"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type fakeStore struct {
 	ready       bool
 	deployments []string
 	replicas    map[string]int32
+	bounds      map[string][2]*int32 // name -> {min, max}
 	setErr      error
 }
 
@@ -31,10 +52,21 @@ func (f *fakeStore) GetReplicas(ctx context.Context, name string) (int32, bool,
 	return v, ok, nil
 }
 
+func (f *fakeStore) DescribeDeployment(ctx context.Context, name string) (kube.Deployment, bool, error) {
+	v, ok := f.replicas[name]
+	b := f.bounds[name]
+	return kube.Deployment{Name: name, Replicas: v, MinReplicas: b[0], MaxReplicas: b[1]}, ok, nil
+}
+
 func (f *fakeStore) SetReplicas(ctx context.Context, name string, replicas int32) error {
 	if f.setErr != nil {
 		return f.setErr
 	}
+	if d, ok, _ := f.DescribeDeployment(ctx, name); ok {
+		if err := d.CheckBounds(replicas); err != nil {
+			return err
+		}
+	}
 	if f.replicas == nil {
 		f.replicas = map[string]int32{}
 	}
@@ -45,6 +77,9 @@ func (f *fakeStore) SetReplicas(ctx context.Context, name string, replicas int32
 
 var _ kube.Store = (*fakeStore)(nil)
 var _ kube.Pinger = (*fakeStore)(nil)
+var _ kube.Describer = (*fakeStore)(nil)
+
+func int32Ptr(v int32) *int32 { return &v }
 
 func TestHealthzOK(t *testing.T) {
 	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, &fakeStore{ready: true})
@@ -84,3 +119,1111 @@ func TestSetReplicasRejectsNegative(t *testing.T) {
 		t.Fatalf("expected 400, got %d (%s)", rr.Code, rr.Body.String())
 	}
 }
+
+func TestSetReplicasRejectsOutOfBounds(t *testing.T) {
+	store := &fakeStore{
+		ready:    true,
+		replicas: map[string]int32{"frontend": 3},
+		bounds:   map[string][2]*int32{"frontend": {int32Ptr(2), int32Ptr(10)}},
+	}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store)
+
+	for body, bound := range map[string]string{
+		`{"replicas":1}`:  kube.MinReplicasAnnotation,
+		`{"replicas":11}`: kube.MaxReplicasAnnotation,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d (%s)", body, rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), bound) {
+			t.Fatalf("%s: expected error naming %s, got %s", body, bound, rr.Body.String())
+		}
+	}
+	if store.replicas["frontend"] != 3 {
+		t.Fatalf("replicas should be unchanged, got %d", store.replicas["frontend"])
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/deployments/frontend/replicas", nil)
+	rr := httptest.NewRecorder()
+	s.routeAPIv1(rr, req)
+
+	var got getReplicasResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rr.Body.String())
+	}
+	if got.MinReplicas == nil || *got.MinReplicas != 2 || got.MaxReplicas == nil || *got.MaxReplicas != 10 {
+		t.Fatalf("expected bounds in response, got %s", rr.Body.String())
+	}
+}
+
+func TestWriteRateLimitPerClient(t *testing.T) {
+	s := New(config.Config{
+		ListenAddr:          ":0",
+		ProbeListenAddr:     ":0",
+		RateLimitWriteRPS:   0.01,
+		RateLimitWriteBurst: 1,
+	}, &fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}})
+
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(`{"replicas":2}`))
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		s.apiSrv.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	before := rejectedCount(rejectReasonWrite)
+
+	if rr := post("192.0.2.1:1234"); rr.Code != http.StatusOK {
+		t.Fatalf("first write expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr := post("192.0.2.1:1234")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("second write expected 429, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+	if got := rejectedCount(rejectReasonWrite); got != before+1 {
+		t.Fatalf("expected rejected counter %v, got %v", before+1, got)
+	}
+
+	// other clients and reads have their own buckets
+	if rr := post("192.0.2.2:1234"); rr.Code != http.StatusOK {
+		t.Fatalf("other client expected 200, got %d", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/deployments/frontend/replicas", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rr = httptest.NewRecorder()
+	s.apiSrv.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("read expected 200, got %d", rr.Code)
+	}
+}
+
+func rejectedCount(reason string) float64 {
+	return testutil.ToFloat64(metrics.RejectedRequests.WithLabelValues(reason))
+}
+
+func TestInstrumentRecordsRouteTemplate(t *testing.T) {
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, &fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}})
+	counter := metrics.HTTPRequests.WithLabelValues("api", "/api/v1/deployments/{name}/replicas", http.MethodGet, "404")
+	before := testutil.ToFloat64(counter)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/deployments/missing/replicas", nil)
+	rr := httptest.NewRecorder()
+	s.apiSrv.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if got := testutil.ToFloat64(counter); got != before+1 {
+		t.Fatalf("expected request counter %v, got %v", before+1, got)
+	}
+}
+
+func TestHandshakeFailureReason(t *testing.T) {
+	tests := map[string]string{
+		"http: TLS handshake error from 127.0.0.1:1: tls: client didn't provide a certificate":                                         "no_client_certificate",
+		"http: TLS handshake error from 127.0.0.1:1: tls: failed to verify certificate: x509: certificate signed by unknown authority": "unknown_ca",
+		"http: TLS handshake error from 127.0.0.1:1: client certificate serial 3 has been revoked":                                     "revoked_certificate",
+		"http: TLS handshake error from 127.0.0.1:1: EOF":                                                                              "eof",
+		"http: TLS handshake error from 127.0.0.1:1: something else":                                                                   "other",
+	}
+	for msg, want := range tests {
+		if got := handshakeFailureReason(msg); got != want {
+			t.Errorf("%q: expected %s, got %s", msg, want, got)
+		}
+	}
+}
+
+func TestRequestIDEchoedAndPropagated(t *testing.T) {
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, &fakeStore{ready: true})
+
+	rr := httptest.NewRecorder()
+	s.apiSrv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/deployments", nil))
+	if id := rr.Header().Get(requestIDHeader); len(id) != 32 {
+		t.Fatalf("expected generated request id, got %q", id)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(requestIDHeader, "caller-123")
+	rr = httptest.NewRecorder()
+	s.probeSrv.Handler.ServeHTTP(rr, req)
+	if id := rr.Header().Get(requestIDHeader); id != "caller-123" {
+		t.Fatalf("expected propagated request id, got %q", id)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(requestIDHeader, "bad id\twith spaces")
+	rr = httptest.NewRecorder()
+	s.probeSrv.Handler.ServeHTTP(rr, req)
+	if id := rr.Header().Get(requestIDHeader); id == "bad id\twith spaces" || id == "" {
+		t.Fatalf("expected invalid request id to be replaced, got %q", id)
+	}
+}
+
+func TestTracingContinuesIncomingTraceparent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, &fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/deployments/frontend/replicas", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	s.apiSrv.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Fatalf("span %s not part of incoming trace", span.Name())
+		}
+		names[span.Name()] = true
+	}
+	for _, want := range []string{"GET /api/v1/deployments/{name}/replicas", "api.handleGetReplicas"} {
+		if !names[want] {
+			t.Fatalf("missing span %q (got %v)", want, names)
+		}
+	}
+}
+
+type recordingSink struct {
+	events []audit.Event
+	err    error
+}
+
+func (s *recordingSink) Write(e audit.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestSetReplicasWritesAuditRecords(t *testing.T) {
+	sink := &recordingSink{}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", Namespace: "prod"},
+		&fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}},
+		WithAuditLogger(audit.New(false, sink)))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(`{"replicas":3}`))
+	req.RemoteAddr = "192.0.2.7:4321"
+	req.Header.Set(requestIDHeader, "req-1")
+	rr := httptest.NewRecorder()
+	s.apiSrv.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("expected attempt and result records, got %+v", sink.events)
+	}
+	attempt, result := sink.events[0], sink.events[1]
+	if attempt.Stage != audit.StageAttempt || result.Stage != audit.StageResult || result.Result != audit.ResultSuccess {
+		t.Fatalf("unexpected stages/result: %+v", sink.events)
+	}
+	if attempt.Caller != "192.0.2.7" || attempt.SourceIP != "192.0.2.7" || attempt.RequestID != "req-1" ||
+		attempt.Namespace != "prod" || attempt.Deployment != "frontend" || attempt.RequestedReplicas != 3 {
+		t.Fatalf("unexpected attempt record: %+v", attempt)
+	}
+	if attempt.PreviousReplicas == nil || *attempt.PreviousReplicas != 1 {
+		t.Fatalf("expected previous replicas 1, got %v", attempt.PreviousReplicas)
+	}
+}
+
+func TestRefusedWritesAreAudited(t *testing.T) {
+	sink := &recordingSink{}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", RateLimitWriteRPS: 0.01, RateLimitWriteBurst: 1},
+		&fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}},
+		WithAuditLogger(audit.New(false, sink)))
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		s.apiSrv.Handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := post(`{"replicas":`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+	if code := post(`{"replicas":3}`); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("expected two refusal records, got %+v", sink.events)
+	}
+	for i, want := range []string{audit.ResultInvalid, audit.ResultDenied} {
+		e := sink.events[i]
+		if e.Stage != audit.StageResult || e.Result != want || e.Deployment != "frontend" || e.Error == "" {
+			t.Fatalf("record %d: expected %s result for frontend, got %+v", i, want, e)
+		}
+	}
+}
+
+func TestSetReplicasAuditFailClosed(t *testing.T) {
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store,
+		WithAuditLogger(audit.New(true, &recordingSink{err: errors.New("disk full")})))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(`{"replicas":3}`))
+	rr := httptest.NewRecorder()
+	s.routeAPIv1(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if store.replicas["frontend"] != 1 {
+		t.Fatalf("expected write to be blocked, replicas now %d", store.replicas["frontend"])
+	}
+}
+
+type inspectableStore struct {
+	*fakeStore
+	stats kube.InformerStats
+}
+
+func (s inspectableStore) Snapshot() []kube.Deployment {
+	return []kube.Deployment{{Name: "frontend", Replicas: 1, ResourceVersion: "42"}}
+}
+
+func (s inspectableStore) InformerStats() kube.InformerStats { return s.stats }
+
+func TestAdminDebugEndpoints(t *testing.T) {
+	store := inspectableStore{
+		fakeStore: &fakeStore{ready: true},
+		stats:     kube.InformerStats{Namespace: "prod", CacheSize: 1, Synced: true, ResourceVersion: "42", LastWatchError: "forbidden"},
+	}
+	s := New(config.Config{
+		ListenAddr:      ":0",
+		ProbeListenAddr: ":0",
+		AdminListenAddr: "127.0.0.1:0",
+		TLSKeyFile:      "/etc/tls/server.key",
+	}, store)
+	if s.adminSrv == nil {
+		t.Fatalf("expected admin server when AdminListenAddr is set")
+	}
+
+	get := func(path string) string {
+		rr := httptest.NewRecorder()
+		s.adminSrv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d (%s)", path, rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	if body := get("/debug/cache"); !strings.Contains(body, `"resourceVersion":"42"`) {
+		t.Fatalf("unexpected cache dump: %s", body)
+	}
+	if body := get("/debug/informer"); !strings.Contains(body, `"lastWatchError":"forbidden"`) {
+		t.Fatalf("unexpected informer stats: %s", body)
+	}
+	if body := get("/debug/config"); strings.Contains(body, "server.key") {
+		t.Fatalf("expected key path to be redacted: %s", body)
+	}
+	get("/debug/pprof/")
+
+	if New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store).adminSrv != nil {
+		t.Fatalf("expected admin server to be disabled by default")
+	}
+}
+
+type pingCountingStore struct {
+	*fakeStore
+	pings   int
+	pingErr error
+}
+
+func (s *pingCountingStore) Ping(ctx context.Context) error {
+	s.pings++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.pingErr
+}
+
+func TestReadyzVerboseAndExclude(t *testing.T) {
+	store := &pingCountingStore{fakeStore: &fakeStore{ready: true}, pingErr: errors.New("connection refused")}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store)
+
+	probe := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.probeSrv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := probe("/readyz?verbose")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var resp healthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rr.Body.String())
+	}
+	got := map[string]string{}
+	for _, c := range resp.Checks {
+		got[c.Name] = c.Status
+		if c.Duration == "" {
+			t.Fatalf("expected duration for %s", c.Name)
+		}
+	}
+	if got[checkCacheSynced] != "ok" || got[checkKubernetes] != "failed" {
+		t.Fatalf("unexpected check results: %+v", resp.Checks)
+	}
+
+	if rr := probe("/readyz?exclude=" + checkKubernetes); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 with kubernetes excluded, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := probe("/livez?verbose"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), checkPing) {
+		t.Fatalf("expected live, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReadyzCachesPing(t *testing.T) {
+	store := &pingCountingStore{fakeStore: &fakeStore{ready: true}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", ReadyzPingTTL: time.Minute}, store)
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		s.handleReadyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+	}
+	if store.pings != 1 {
+		t.Fatalf("expected 1 kubernetes ping within the TTL, got %d", store.pings)
+	}
+}
+
+func TestReadyzDoesNotCacheAbortedPing(t *testing.T) {
+	store := &pingCountingStore{fakeStore: &fakeStore{ready: true}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", ReadyzPingTTL: time.Minute}, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr := httptest.NewRecorder()
+	s.handleReadyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(ctx))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for an aborted probe, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleReadyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK || store.pings != 2 {
+		t.Fatalf("expected the next probe to ping again and succeed, got %d after %d pings", rr.Code, store.pings)
+	}
+}
+
+func TestCertExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{NotAfter: now.Add(48 * time.Hour)}
+
+	if detail, err := certExpiry(cert, now); err != nil || !strings.Contains(detail, "48h") {
+		t.Fatalf("expected valid cert with remaining time, got %q, %v", detail, err)
+	}
+	if _, err := certExpiry(cert, now.Add(72*time.Hour)); err == nil {
+		t.Fatalf("expected expired cert to fail")
+	}
+}
+
+type agingStore struct {
+	*fakeStore
+	age time.Duration
+}
+
+func (s agingStore) CacheAge() time.Duration { return s.age }
+
+func TestAPIResponsesIncludeCacheAge(t *testing.T) {
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"},
+		agingStore{fakeStore: &fakeStore{ready: true}, age: 90 * time.Second})
+
+	rr := httptest.NewRecorder()
+	s.routeAPIv1(rr, httptest.NewRequest(http.MethodGet, "/api/v1/deployments", nil))
+	if got := rr.Header().Get(cacheAgeHeader); got != "90" {
+		t.Fatalf("expected %s: 90, got %q", cacheAgeHeader, got)
+	}
+}
+
+func TestGetHistoryFiltersByTime(t *testing.T) {
+	hist, err := history.New(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, replicas := range []int32{1, 3, 5} {
+		hist.Observe(kube.Observation{Name: "frontend", Replicas: replicas, ObservedAt: t0.Add(time.Duration(i) * time.Hour)})
+	}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"},
+		&fakeStore{ready: true, replicas: map[string]int32{"frontend": 5}}, WithHistory(hist))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := get("/api/v1/deployments/frontend/history?since=2025-03-01T12:30:00Z")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var resp historyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Entries) != 2 || resp.Entries[0].Replicas != 3 {
+		t.Fatalf("unexpected entries: %+v", resp.Entries)
+	}
+
+	if rr := get("/api/v1/deployments/frontend/history?since=yesterday"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid since, got %d", rr.Code)
+	}
+	if rr := get("/api/v1/deployments/missing/history"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown deployment, got %d", rr.Code)
+	}
+}
+
+func TestListSchedules(t *testing.T) {
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 3}}
+	sched, err := scheduler.New([]scheduler.Rule{
+		{Name: "night", Deployment: "frontend", Replicas: 1, Schedule: "0 20 * * *", Timezone: "Europe/Berlin"},
+	}, scheduler.Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store, WithScheduler(sched))
+
+	rr := httptest.NewRecorder()
+	s.routeAPIv1(rr, httptest.NewRequest(http.MethodGet, "/api/v1/schedules", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var resp schedulesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Schedules) != 1 || resp.Schedules[0].Name != "night" || resp.Schedules[0].NextRun == nil || resp.Schedules[0].LastRun != nil {
+		t.Fatalf("unexpected schedules: %+v", resp.Schedules)
+	}
+
+	rr = httptest.NewRecorder()
+	s.routeAPIv1(rr, httptest.NewRequest(http.MethodPost, "/api/v1/schedules", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestSetReplicasFreezeWindow(t *testing.T) {
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	freezes, err := freeze.New([]freeze.Window{
+		{Name: "release-freeze", Start: &start, End: &end, Allow: []string{"192.0.2.9"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{}
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store,
+		WithAuditLogger(audit.New(false, sink)), WithFreezeCalendar(freezes))
+
+	post := func(remote, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(body))
+		req.RemoteAddr = remote + ":4321"
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, req)
+		return rr
+	}
+
+	rr := post("192.0.2.7", `{"replicas":3}`)
+	if rr.Code != http.StatusLocked || !strings.Contains(rr.Body.String(), `"window":"release-freeze"`) {
+		t.Fatalf("expected 423 naming the window, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if store.replicas["frontend"] != 1 {
+		t.Fatalf("expected write to be blocked, replicas now %d", store.replicas["frontend"])
+	}
+	if len(sink.events) != 2 || sink.events[1].Result != audit.ResultRejected || sink.events[1].FreezeWindow != "release-freeze" {
+		t.Fatalf("expected rejected audit records, got %+v", sink.events)
+	}
+
+	if rr := post("192.0.2.7", `{"replicas":3,"breakGlass":true}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for break-glass without reason, got %d", rr.Code)
+	}
+
+	sink.events = nil
+	if rr := post("192.0.2.7", `{"replicas":3,"breakGlass":true,"reason":"outage"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected break-glass to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if len(sink.events) != 2 || !sink.events[0].BreakGlass || sink.events[0].FreezeWindow != "release-freeze" || sink.events[0].Reason != "outage" {
+		t.Fatalf("expected break-glass audit records, got %+v", sink.events)
+	}
+
+	if rr := post("192.0.2.9", `{"replicas":4}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected allowed identity to bypass the freeze, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestBreakGlassRequiresAudit(t *testing.T) {
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	freezes, err := freeze.New([]freeze.Window{{Name: "release-freeze", Start: &start, End: &end}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 1}}
+	// Not fail-closed: ordinary writes would proceed, break-glass must not.
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store,
+		WithAuditLogger(audit.New(false, &recordingSink{err: errors.New("disk full")})), WithFreezeCalendar(freezes))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas",
+		strings.NewReader(`{"replicas":3,"breakGlass":true,"reason":"outage"}`))
+	rr := httptest.NewRecorder()
+	s.routeAPIv1(rr, req)
+	if rr.Code != http.StatusServiceUnavailable || store.replicas["frontend"] != 1 {
+		t.Fatalf("expected 503 and no write, got %d, replicas %d", rr.Code, store.replicas["frontend"])
+	}
+}
+
+func TestApprovalWorkflow(t *testing.T) {
+	sink := &recordingSink{}
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 4, "backend": 4}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store,
+		WithAuditLogger(audit.New(false, sink)),
+		WithApprovals(approval.NewQueue(time.Hour, nil), approval.Rules{ScaleToZero: true, MaxChangePercent: 50}))
+
+	// Identities come from client certificates; "" sends none.
+	do := func(method, target, identity, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "192.0.2.7:4321"
+		if identity != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: identity}}
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, req)
+		return rr
+	}
+
+	// Small changes are applied directly, even without a certificate identity.
+	if rr := do(http.MethodPost, "/api/v1/deployments/frontend/replicas", "", `{"replicas":5}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a small change, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/v1/deployments/missing/replicas", "alice", `{"replicas":0}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an uncached deployment, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/v1/deployments/frontend/replicas", "", `{"replicas":0}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a risky change without a certificate identity, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := do(http.MethodPost, "/api/v1/deployments/frontend/replicas", "alice", `{"replicas":0,"reason":"decommission"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var pending approval.Change
+	if err := json.Unmarshal(rr.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if pending.ID == "" || pending.State != approval.StatePending || pending.RequestedBy != "alice" || store.replicas["frontend"] != 5 {
+		t.Fatalf("unexpected pending change %+v, replicas %d", pending, store.replicas["frontend"])
+	}
+	if last := sink.events[len(sink.events)-1]; last.Result != audit.ResultPending || last.ChangeID != pending.ID {
+		t.Fatalf("expected pending audit record, got %+v", last)
+	}
+
+	approve := "/api/v1/changes/" + pending.ID + ":approve"
+	if rr := do(http.MethodPost, approve, "alice", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for self-approval, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	// The same remote IP is not the same identity, and no certificate is no identity.
+	if rr := do(http.MethodPost, approve, "", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for approval without a certificate identity, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, approve, "bob", ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"state":"applied"`) {
+		t.Fatalf("expected 200 applied, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if store.replicas["frontend"] != 0 {
+		t.Fatalf("expected approved change to be applied, replicas %d", store.replicas["frontend"])
+	}
+	if last := sink.events[len(sink.events)-1]; last.Result != audit.ResultSuccess || last.DecidedBy != "bob" || last.Caller != "alice" {
+		t.Fatalf("unexpected audit record for the applied change: %+v", last)
+	}
+	if rr := do(http.MethodPost, approve, "carol", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a decided change, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/api/v1/deployments/backend/replicas", "alice", `{"replicas":20}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a large change, got %d", rr.Code)
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rr := do(http.MethodPost, "/api/v1/changes/"+pending.ID+":reject", "bob", `{"reason":"too big"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for reject, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if store.replicas["backend"] != 4 {
+		t.Fatalf("expected rejected change not to be applied, replicas %d", store.replicas["backend"])
+	}
+
+	rr = do(http.MethodGet, "/api/v1/changes", "", "")
+	var list listChangesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/api/v1/changes/nope", "", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestApprovalKeepsBreakGlassDuringFreeze(t *testing.T) {
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	freezes, err := freeze.New([]freeze.Window{{Name: "release-freeze", Start: &start, End: &end}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{}
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 4}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store,
+		WithAuditLogger(audit.New(false, sink)), WithFreezeCalendar(freezes),
+		WithApprovals(approval.NewQueue(time.Hour, nil), approval.Rules{ScaleToZero: true}))
+
+	do := func(target, identity, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: identity}}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, req)
+		return rr
+	}
+
+	rr := do("/api/v1/deployments/frontend/replicas", "alice", `{"replicas":0,"breakGlass":true,"reason":"runaway costs"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var pending approval.Change
+	if err := json.Unmarshal(rr.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !pending.BreakGlass || pending.Reason != "runaway costs" {
+		t.Fatalf("expected the pending change to keep break-glass and its reason, got %+v", pending)
+	}
+
+	// The approver applies the change inside the freeze on the requester's break-glass.
+	if rr := do("/api/v1/changes/"+pending.ID+":approve", "bob", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if store.replicas["frontend"] != 0 {
+		t.Fatalf("expected the approved change to be applied, replicas %d", store.replicas["frontend"])
+	}
+	last := sink.events[len(sink.events)-1]
+	if last.Result != audit.ResultSuccess || !last.BreakGlass || last.FreezeWindow != "release-freeze" || last.DecidedBy != "bob" {
+		t.Fatalf("unexpected audit record for the applied change: %+v", last)
+	}
+}
+
+type unreadableStore struct {
+	*fakeStore
+}
+
+func (s unreadableStore) GetReplicas(ctx context.Context, name string) (int32, bool, error) {
+	return 0, false, errors.New("cache unavailable")
+}
+
+func TestApprovalGateAuditsUnknownCount(t *testing.T) {
+	sink := &recordingSink{}
+	store := unreadableStore{&fakeStore{ready: true, replicas: map[string]int32{"frontend": 4}}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store,
+		WithAuditLogger(audit.New(false, sink)),
+		WithApprovals(approval.NewQueue(time.Hour, nil), approval.Rules{ScaleToZero: true}))
+
+	rr := httptest.NewRecorder()
+	s.routeAPIv1(rr, httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(`{"replicas":0}`)))
+	if rr.Code != http.StatusServiceUnavailable || store.replicas["frontend"] != 4 {
+		t.Fatalf("expected 503 and no write, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if len(sink.events) != 2 || sink.events[1].Result != audit.ResultError || !strings.Contains(sink.events[1].Error, "cache unavailable") {
+		t.Fatalf("expected an audited refusal, got %+v", sink.events)
+	}
+}
+
+func TestSetReplicasChangeLimitErrors(t *testing.T) {
+	store := &fakeStore{ready: true, replicas: map[string]int32{"frontend": 4}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store)
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/deployments/frontend/replicas", strings.NewReader(`{"replicas":9}`))
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, req)
+		return rr
+	}
+
+	store.setErr = &kube.CooldownError{Name: "frontend", Cooldown: time.Minute, NextAllowed: time.Now().Add(41 * time.Second), RetryAfter: 41500 * time.Millisecond}
+	rr := post()
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "42" || !strings.Contains(rr.Body.String(), `"retryAfterSeconds":42`) {
+		t.Fatalf("expected 429 with Retry-After 42, got %d %q (%s)", rr.Code, rr.Header().Get("Retry-After"), rr.Body.String())
+	}
+
+	store.setErr = &kube.StepError{Name: "frontend", Current: 4, Requested: 9, Limit: 50, Percent: true}
+	if rr := post(); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"percent":true`) {
+		t.Fatalf("expected 422 step error, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	store.setErr = &kube.QuotaError{Name: "frontend", Quota: "compute", Violations: []kube.QuotaViolation{{
+		Resource: "requests.cpu", Hard: resource.MustParse("2"), Used: resource.MustParse("1"), Requested: resource.MustParse("2500m"),
+	}}}
+	if rr := post(); rr.Code != http.StatusUnprocessableEntity ||
+		!strings.Contains(rr.Body.String(), `"quota":"compute"`) || !strings.Contains(rr.Body.String(), `"requested":"2500m"`) {
+		t.Fatalf("expected 422 quota error, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+type hpaStore struct {
+	*fakeStore
+	hpa kube.HPA
+}
+
+func (s hpaStore) DescribeDeployment(ctx context.Context, name string) (kube.Deployment, bool, error) {
+	d, ok, err := s.fakeStore.DescribeDeployment(ctx, name)
+	d.HPA = &s.hpa
+	return d, ok, err
+}
+
+func (s hpaStore) SetReplicas(ctx context.Context, name string, replicas int32) error {
+	if !kube.HPAOverrideFromContext(ctx) {
+		return &kube.HPAConflictError{Name: name, HPA: s.hpa}
+	}
+	return s.fakeStore.SetReplicas(ctx, name, replicas)
+}
+
+func TestSetReplicasHPAConflict(t *testing.T) {
+	store := hpaStore{
+		fakeStore: &fakeStore{ready: true, replicas: map[string]int32{"frontend": 3}},
+		hpa:       kube.HPA{Name: "frontend", MinReplicas: 2, MaxReplicas: 10, CurrentReplicas: 3, DesiredReplicas: 3},
+	}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0"}, store)
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, httptest.NewRequest(method, "/api/v1/deployments/frontend/replicas", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := do(http.MethodGet, ""); rr.Code != http.StatusOK ||
+		!strings.Contains(rr.Body.String(), `"hpa":{"name":"frontend","minReplicas":2,"maxReplicas":10,"currentReplicas":3,"desiredReplicas":3}`) {
+		t.Fatalf("expected hpa in response, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, `{"replicas":5}`); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), `"maxReplicas":10`) {
+		t.Fatalf("expected 409 hpa conflict, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, `{"replicas":5,"hpaOverride":true}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected override to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := store.replicas["frontend"]; got != 5 {
+		t.Fatalf("expected 5 replicas, got %d", got)
+	}
+}
+
+type pdbStore struct {
+	*fakeStore
+	pdb kube.PDB
+}
+
+func (s pdbStore) DescribeDeployment(ctx context.Context, name string) (kube.Deployment, bool, error) {
+	d, ok, err := s.fakeStore.DescribeDeployment(ctx, name)
+	d.PDB = &s.pdb
+	return d, ok, err
+}
+
+func TestSetReplicasPDBModes(t *testing.T) {
+	minAvailable := intstr.FromInt32(2)
+	store := pdbStore{
+		fakeStore: &fakeStore{ready: true, replicas: map[string]int32{"frontend": 4}},
+		pdb:       kube.PDB{Name: "frontend-pdb", MinAvailable: &minAvailable, ExpectedPods: 4, CurrentHealthy: 4, DesiredHealthy: 2, DisruptionsAllowed: 2},
+	}
+
+	do := func(s *Server, method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, httptest.NewRequest(method, "/api/v1/deployments/frontend/replicas", strings.NewReader(body)))
+		return rr
+	}
+
+	reject := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", PDBMode: config.PDBModeReject}, store)
+	if rr := do(reject, http.MethodGet, ""); !strings.Contains(rr.Body.String(), `"pdb":{"name":"frontend-pdb","minAvailable":2,`) {
+		t.Fatalf("expected pdb in response, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(reject, http.MethodPost, `{"replicas":2}`); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"expectedPods":2`) {
+		t.Fatalf("expected 422 pdb error, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(reject, http.MethodPost, `{"replicas":3}`); rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "warnings") {
+		t.Fatalf("expected scale-down leaving disruptions to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	store.replicas["frontend"] = 4
+	warn := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", PDBMode: config.PDBModeWarn}, store)
+	if rr := do(warn, http.MethodPost, `{"replicas":2}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"warnings":["scaling deployment`) {
+		t.Fatalf("expected 200 with warning, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := store.replicas["frontend"]; got != 2 {
+		t.Fatalf("expected warn mode to apply the change, got %d replicas", got)
+	}
+}
+
+// hibernateStore records hibernated replicas in memory, like the annotation.
+type hibernateStore struct {
+	*fakeStore
+	hibernated map[string]int32
+	labels     map[string]map[string]string
+	pdbs       map[string]*kube.PDB
+}
+
+func (s hibernateStore) DescribeDeployment(ctx context.Context, name string) (kube.Deployment, bool, error) {
+	d, ok, err := s.fakeStore.DescribeDeployment(ctx, name)
+	d.Labels = s.labels[name]
+	d.PDB = s.pdbs[name]
+	if v, hibernated := s.hibernated[name]; hibernated {
+		d.HibernatedReplicas = &v
+	}
+	return d, ok, err
+}
+
+func (s hibernateStore) Hibernate(ctx context.Context, name string) (bool, error) {
+	if _, ok := s.hibernated[name]; ok {
+		return false, nil
+	}
+	s.hibernated[name] = s.replicas[name]
+	s.replicas[name] = 0
+	return true, nil
+}
+
+func (s hibernateStore) Wake(ctx context.Context, name string) (int32, error) {
+	v, ok := s.hibernated[name]
+	if !ok {
+		return 0, kube.ErrNotHibernated
+	}
+	delete(s.hibernated, name)
+	s.replicas[name] = v
+	return v, nil
+}
+
+func TestNamespaceHibernateAndWake(t *testing.T) {
+	store := hibernateStore{
+		fakeStore: &fakeStore{
+			ready:       true,
+			deployments: []string{"web", "db", "worker"},
+			replicas:    map[string]int32{"web": 3, "db": 1, "worker": 2},
+		},
+		hibernated: map[string]int32{},
+		labels:     map[string]map[string]string{"db": {kube.HibernateExcludeLabel: "true"}},
+	}
+	var buf bytes.Buffer
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", Namespace: "preview"}, store,
+		WithAuditLogger(audit.New(false, audit.NewWriterSink(&buf))))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+
+	var op namespaceOperationResponse
+	decode(do(http.MethodPost, "/api/v1/namespace:hibernate", `{"reason":"end of day"}`), &op)
+	if op.Total != 3 || op.Changed != 2 || op.Excluded != 1 || op.Failed != 0 {
+		t.Fatalf("unexpected hibernate result: %+v", op)
+	}
+	if store.replicas["web"] != 0 || store.replicas["worker"] != 0 || store.replicas["db"] != 1 {
+		t.Fatalf("unexpected replicas after hibernate: %v", store.replicas)
+	}
+	if got := op.Deployments[1]; got.Name != "web" || got.Result != resultHibernated || got.Replicas != 0 || *got.PreviousReplicas != 3 {
+		t.Fatalf("unexpected web result: %+v", got)
+	}
+	if !strings.Contains(buf.String(), `"operation":"hibernate"`) || !strings.Contains(buf.String(), `"reason":"end of day"`) {
+		t.Fatalf("expected audited hibernation, got %s", buf.String())
+	}
+
+	// Repeating it changes nothing.
+	decode(do(http.MethodPost, "/api/v1/namespace:hibernate", ""), &op)
+	if op.Changed != 0 || op.Skipped != 2 {
+		t.Fatalf("expected idempotent hibernate, got %+v", op)
+	}
+
+	var ns namespaceResponse
+	decode(do(http.MethodGet, "/api/v1/namespace", ""), &ns)
+	if ns.State != namespaceHibernated || ns.Hibernated != 2 || ns.Excluded != 1 {
+		t.Fatalf("unexpected namespace state: %+v", ns)
+	}
+
+	decode(do(http.MethodPost, "/api/v1/namespace:wake", ""), &op)
+	if op.Changed != 2 || store.replicas["web"] != 3 || store.replicas["worker"] != 2 {
+		t.Fatalf("unexpected wake result: %+v %v", op, store.replicas)
+	}
+	decode(do(http.MethodGet, "/api/v1/namespace", ""), &ns)
+	if ns.State != namespaceAwake {
+		t.Fatalf("expected awake namespace, got %+v", ns)
+	}
+
+	if rr := do(http.MethodGet, "/api/v1/namespace:wake", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/v1/namespace:delete", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestNamespaceHibernateAppliesPolicy(t *testing.T) {
+	minAvailable := intstr.FromInt32(2)
+	store := hibernateStore{
+		fakeStore: &fakeStore{
+			ready:       true,
+			deployments: []string{"web", "worker", "cache"},
+			replicas:    map[string]int32{"web": 3, "worker": 2, "cache": 1},
+		},
+		hibernated: map[string]int32{},
+		// The PDB also covers pods of another workload, so it would allow no evictions.
+		pdbs: map[string]*kube.PDB{"cache": {Name: "shared", MinAvailable: &minAvailable, ExpectedPods: 3}},
+	}
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	freezes, err := freeze.New([]freeze.Window{{Name: "release-freeze", Start: &start, End: &end}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{}
+	cfg := config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", Namespace: "preview", PDBMode: config.PDBModeReject}
+	s := New(cfg, store, WithAuditLogger(audit.New(false, sink)), WithFreezeCalendar(freezes),
+		WithApprovals(approval.NewQueue(time.Hour, nil), approval.Rules{ScaleToZero: true}))
+
+	do := func(ctx context.Context, body string) (*httptest.ResponseRecorder, namespaceOperationResponse) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, httptest.NewRequest(http.MethodPost, "/api/v1/namespace:hibernate", strings.NewReader(body)).WithContext(ctx))
+		var op namespaceOperationResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &op); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rr, op
+	}
+
+	_, op := do(context.Background(), "")
+	if op.Changed != 0 || op.Failed != 3 || !strings.Contains(op.Deployments[1].Error, "release-freeze") {
+		t.Fatalf("expected every deployment to be rejected by the freeze, got %+v", op)
+	}
+
+	if rr, _ := do(context.Background(), `{"breakGlass":true}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for breakGlass without a reason, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	// Break-glass overrides the freeze, but not approvals: scaling to zero needs a
+	// second approver, which a namespace operation cannot wait for.
+	_, op = do(context.Background(), `{"breakGlass":true,"reason":"cost incident"}`)
+	if op.Changed != 0 || op.Failed != 3 {
+		t.Fatalf("expected every deployment to be rejected, got %+v", op)
+	}
+	if got := op.Deployments[1]; got.Name != "web" || got.Result != resultRejected || !strings.Contains(got.Error, "requires approval (scale to zero)") {
+		t.Fatalf("unexpected web result: %+v", got)
+	}
+	if len(store.hibernated) != 0 {
+		t.Fatalf("expected nothing hibernated, got %v", store.hibernated)
+	}
+	if last := sink.events[len(sink.events)-1]; last.Result != audit.ResultRejected || !last.BreakGlass {
+		t.Fatalf("expected an audited break-glass rejection, got %+v", last)
+	}
+
+	// A deadline that has already passed defers everything.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, op := do(ctx, `{"breakGlass":true,"reason":"cost incident"}`); op.Deferred != 3 || op.Deployments[0].Result != resultDeferred || len(store.hibernated) != 0 {
+		t.Fatalf("expected every deployment to be deferred, got %+v", op)
+	}
+
+	// Without approval rules, break-glass hibernates inside the freeze, but the PDB
+	// check still applies.
+	s = New(cfg, store, WithAuditLogger(audit.New(false, sink)), WithFreezeCalendar(freezes))
+	_, op = do(context.Background(), `{"breakGlass":true,"reason":"cost incident"}`)
+	if op.Changed != 2 || op.Failed != 1 || op.Deployments[0].Name != "cache" || op.Deployments[0].Result != resultRejected {
+		t.Fatalf("expected cache to be rejected by its PDB, got %+v", op)
+	}
+	if store.replicas["web"] != 0 || store.replicas["worker"] != 0 || store.replicas["cache"] != 1 {
+		t.Fatalf("unexpected replicas: %v", store.replicas)
+	}
+	var breakGlass int
+	for _, e := range sink.events {
+		if e.BreakGlass && e.FreezeWindow == "release-freeze" && e.Result == audit.ResultSuccess {
+			breakGlass++
+		}
+	}
+	if breakGlass != 2 {
+		t.Fatalf("expected 2 audited break-glass hibernations, got %d", breakGlass)
+	}
+}
+
+type countingStore struct {
+	*fakeStore
+}
+
+func (s countingStore) TotalReplicas() (int64, int) {
+	var total int64
+	for _, v := range s.replicas {
+		total += int64(v)
+	}
+	return total, len(s.replicas)
+}
+
+func TestReplicaBudget(t *testing.T) {
+	store := countingStore{&fakeStore{ready: true, replicas: map[string]int32{"frontend": 4, "backend": 4}}}
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", Namespace: "prod", ReplicaBudget: 10}, store)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.routeAPIv1(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/v1/deployments/frontend/replicas", `{"replicas":7}`)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"remaining":2`) {
+		t.Fatalf("expected 422 over budget, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/v1/deployments/frontend/replicas", `{"replicas":6}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected scale-up within budget to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = do(http.MethodGet, "/api/v1/budget", "")
+	var resp budgetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Used != 10 || resp.Budget == nil || *resp.Budget != 10 || resp.Remaining == nil || *resp.Remaining != 0 || resp.Deployments != 2 {
+		t.Fatalf("unexpected budget: %s", rr.Body.String())
+	}
+
+	// Over budget (e.g. after lowering it), scale-downs still go through.
+	s.cfg.ReplicaBudget = 5
+	if rr := do(http.MethodPost, "/api/v1/deployments/backend/replicas", `{"replicas":2}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected scale-down to succeed over budget, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+// Readiness check names, usable with /readyz?exclude=.
+const (
+	checkCacheSynced = "cache-synced"
+	checkKubernetes  = "kubernetes"
+	checkTLSLoaded   = "tls-loaded"
+	checkCertExpiry  = "cert-expiry"
+	checkPing        = "ping"
+)
+
+// healthCheck is a single named probe check.
+type healthCheck struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+type checkResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks,omitempty"`
+}
+
+// pingCache rate-limits Kubernetes connectivity checks so frequent probes do not each
+// hit the API server.
+type pingCache struct {
+	ttl time.Duration
+
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
+// ping returns the cached result if it is younger than the TTL, otherwise calls p. A
+// ping cut short because the probe went away says nothing about connectivity and is
+// not cached; one that outlives the check timeout is.
+func (c *pingCache) ping(ctx context.Context, p kube.Pinger) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl > 0 && !c.at.IsZero() && time.Since(c.at) < c.ttl {
+		return c.err
+	}
+	err := p.Ping(ctx)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return err
+	}
+	c.err = err
+	c.at = time.Now()
+	return c.err
+}
+
+func (s *Server) readinessChecks() []healthCheck {
+	checks := []healthCheck{
+		{name: checkCacheSynced, run: func(context.Context) (string, error) {
+			if s.store == nil {
+				return "", fmt.Errorf("store not configured")
+			}
+			if !s.store.Ready() {
+				if inspector, ok := s.store.(kube.Inspector); ok {
+					if st := inspector.InformerStats(); st.Stale {
+						return "", fmt.Errorf("cache is stale: last progress %s ago, last watch error: %s",
+							time.Since(st.LastProgress).Round(time.Second), st.LastWatchError)
+					}
+				}
+				return "", fmt.Errorf("cache not synced")
+			}
+			return "", nil
+		}},
+	}
+
+	// Ping is optional so unit tests can provide a lightweight Store implementation.
+	if pinger, ok := s.store.(kube.Pinger); ok {
+		checks = append(checks, healthCheck{name: checkKubernetes, run: func(ctx context.Context) (string, error) {
+			return "", s.ping.ping(ctx, pinger)
+		}})
+	}
+
+	if s.cfg.TLSEnabled {
+		checks = append(checks,
+			healthCheck{name: checkTLSLoaded, run: func(context.Context) (string, error) {
+				if s.serverCert.Load() == nil {
+					return "", fmt.Errorf("tls material not loaded")
+				}
+				return "", nil
+			}},
+			healthCheck{name: checkCertExpiry, run: func(context.Context) (string, error) {
+				return certExpiry(s.serverCert.Load(), time.Now())
+			}},
+		)
+	}
+	return checks
+}
+
+func livenessChecks() []healthCheck {
+	return []healthCheck{
+		{name: checkPing, run: func(context.Context) (string, error) { return "", nil }},
+	}
+}
+
+// certExpiry fails once the server certificate has expired and otherwise reports the
+// remaining validity. An expiring certificate does not fail readiness: every replica
+// shares it, so failing early would only take the whole service down sooner.
+func certExpiry(cert *x509.Certificate, now time.Time) (string, error) {
+	if cert == nil {
+		return "", fmt.Errorf("tls material not loaded")
+	}
+	if now.After(cert.NotAfter) {
+		return "", fmt.Errorf("server certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("expires at %s (in %s)", cert.NotAfter.UTC().Format(time.RFC3339), cert.NotAfter.Sub(now).Round(time.Minute)), nil
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.serveHealth(w, r, "ready", s.readinessChecks())
+}
+
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	s.serveHealth(w, r, "alive", livenessChecks())
+}
+
+// serveHealth runs checks in order. ?exclude=a,b skips checks (repeatable) and ?verbose
+// reports every check with its status and duration; otherwise the first failure is
+// returned as plain text, as before.
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request, okStatus string, checks []healthCheck) {
+	q := r.URL.Query()
+	_, verbose := q["verbose"]
+	excluded := make(map[string]bool)
+	for _, v := range q["exclude"] {
+		for _, name := range strings.Split(v, ",") {
+			excluded[strings.TrimSpace(name)] = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	resp := healthResponse{Status: okStatus}
+	var firstErr error
+	for _, c := range checks {
+		if excluded[c.name] {
+			continue
+		}
+
+		start := time.Now()
+		detail, err := c.run(ctx)
+		res := checkResult{
+			Name:     c.name,
+			Status:   "ok",
+			Duration: time.Since(start).String(),
+			Detail:   detail,
+		}
+		if err != nil {
+			res.Status = "failed"
+			res.Error = err.Error()
+			resp.Status = "not " + okStatus
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		resp.Checks = append(resp.Checks, res)
+	}
+
+	status := http.StatusOK
+	if firstErr != nil {
+		status = http.StatusServiceUnavailable
+	}
+	if verbose {
+		writeJSON(w, status, resp)
+		return
+	}
+	if firstErr != nil {
+		http.Error(w, firstErr.Error(), status)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": okStatus})
+}
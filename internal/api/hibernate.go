@@ -0,0 +1,333 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+// Namespace operations and their per-deployment results.
+const (
+	operationHibernate = "hibernate"
+	operationWake      = "wake"
+
+	resultHibernated = "hibernated"
+	resultWoken      = "woken"
+	resultSkipped    = "skipped" // already in the requested state
+	resultExcluded   = "excluded"
+	resultRejected   = "rejected"
+	resultFailed     = "failed"
+	resultDeferred   = "deferred" // not attempted before namespaceOperationTimeout
+)
+
+// namespaceOperationTimeout bounds a namespace operation so that the response is written
+// within the API listener's 10s WriteTimeout. Deployments not reached by then are
+// reported as deferred; repeating the request picks them up.
+const namespaceOperationTimeout = 8 * time.Second
+
+// Namespace states reported by GET /api/v1/namespace.
+const (
+	namespaceAwake      = "awake"
+	namespaceHibernated = "hibernated"
+	namespacePartial    = "partial"
+)
+
+type namespaceOperationRequest struct {
+	Reason string `json:"reason,omitempty"`
+	Ticket string `json:"ticket,omitempty"`
+	// BreakGlass overrides active freeze windows. It requires a reason.
+	BreakGlass bool `json:"breakGlass,omitempty"`
+}
+
+type namespaceOperationResponse struct {
+	Namespace   string                    `json:"namespace"`
+	Operation   string                    `json:"operation"`
+	Total       int                       `json:"total"`
+	Changed     int                       `json:"changed"`
+	Skipped     int                       `json:"skipped"`
+	Excluded    int                       `json:"excluded"`
+	Failed      int                       `json:"failed"`
+	Deferred    int                       `json:"deferred"`
+	Deployments []namespaceResultResponse `json:"deployments"`
+}
+
+type namespaceResultResponse struct {
+	Name             string `json:"name"`
+	Result           string `json:"result"`
+	Replicas         int32  `json:"replicas"`
+	PreviousReplicas *int32 `json:"previousReplicas,omitempty"`
+	Error            string `json:"error,omitempty"`
+	Warning          string `json:"warning,omitempty"`
+}
+
+type namespaceResponse struct {
+	Namespace   string                   `json:"namespace"`
+	State       string                   `json:"state"`
+	Total       int                      `json:"total"`
+	Hibernated  int                      `json:"hibernated"`
+	Excluded    int                      `json:"excluded"`
+	Deployments []namespaceStateResponse `json:"deployments"`
+}
+
+type namespaceStateResponse struct {
+	Name               string `json:"name"`
+	Replicas           int32  `json:"replicas"`
+	HibernatedReplicas *int32 `json:"hibernatedReplicas,omitempty"`
+	Excluded           bool   `json:"excluded,omitempty"`
+}
+
+// routeNamespace serves GET /api/v1/namespace and POST /api/v1/namespace:hibernate and
+// :wake. action is the part after "namespace:", or empty.
+func (s *Server) routeNamespace(w http.ResponseWriter, r *http.Request, action string) {
+	hibernator, ok := s.store.(kube.Hibernator)
+	describer, ok2 := s.store.(kube.Describer)
+	if !ok || !ok2 {
+		http.Error(w, "hibernation not supported", http.StatusNotImplemented)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		s.handleGetNamespace(w, r, describer)
+	case (action == operationHibernate || action == operationWake) && r.Method == http.MethodPost:
+		s.handleNamespaceOperation(w, r, action, hibernator, describer)
+	case action == "" || action == operationHibernate || action == operationWake:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleGetNamespace(w http.ResponseWriter, r *http.Request, describer kube.Describer) {
+	r, span := startSpan(r, "api.handleGetNamespace")
+	defer span.End()
+
+	names, err := s.store.ListDeployments(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(names)
+
+	resp := namespaceResponse{Namespace: s.cfg.Namespace, Deployments: []namespaceStateResponse{}}
+	for _, name := range names {
+		d, ok, err := describer.DescribeDeployment(r.Context(), name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			continue // deleted since the listing
+		}
+		resp.Total++
+		switch {
+		case d.HibernatedReplicas != nil:
+			resp.Hibernated++
+		case d.Excluded():
+			resp.Excluded++
+		}
+		resp.Deployments = append(resp.Deployments, namespaceStateResponse{
+			Name:               name,
+			Replicas:           d.Replicas,
+			HibernatedReplicas: d.HibernatedReplicas,
+			Excluded:           d.Excluded(),
+		})
+	}
+
+	switch {
+	case resp.Hibernated == 0:
+		resp.State = namespaceAwake
+	case resp.Hibernated == resp.Total-resp.Excluded:
+		resp.State = namespaceHibernated
+	default:
+		resp.State = namespacePartial
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleNamespaceOperation hibernates or wakes every cached deployment in turn. Each one
+// is checked and audited like a single write; a failure does not stop the others, and
+// since deployments already in the requested state are skipped, a partial or deferred
+// run can simply be repeated.
+func (s *Server) handleNamespaceOperation(w http.ResponseWriter, r *http.Request, op string, hibernator kube.Hibernator, describer kube.Describer) {
+	r, span := startSpan(r, "api.handleNamespaceOperation")
+	defer span.End()
+
+	var req namespaceOperationRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MiB
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	// The body is optional.
+	if err := dec.Decode(&req); err != nil && err != io.EOF {
+		s.rejectInvalid(w, r, "invalid json body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Ticket = strings.TrimSpace(req.Ticket)
+	if len(req.Reason) > maxReasonLength {
+		s.rejectInvalid(w, r, fmt.Sprintf("reason must be at most %d bytes", maxReasonLength))
+		return
+	}
+	if len(req.Ticket) > maxTicketLength {
+		s.rejectInvalid(w, r, fmt.Sprintf("ticket must be at most %d bytes", maxTicketLength))
+		return
+	}
+	if req.BreakGlass && req.Reason == "" {
+		s.rejectInvalid(w, r, "reason is required for breakGlass")
+		return
+	}
+
+	names, err := s.store.ListDeployments(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(names)
+
+	change := kube.Change{Caller: clientIdentity(r), Reason: req.Reason, Ticket: req.Ticket}
+	ctx, cancel := context.WithTimeout(kube.WithChange(r.Context(), change), namespaceOperationTimeout)
+	defer cancel()
+	resp := namespaceOperationResponse{
+		Namespace:   s.cfg.Namespace,
+		Operation:   op,
+		Total:       len(names),
+		Deployments: make([]namespaceResultResponse, 0, len(names)),
+	}
+	for i, name := range names {
+		if ctx.Err() != nil {
+			resp.Deferred++
+			resp.Deployments = append(resp.Deployments, namespaceResultResponse{Name: name, Result: resultDeferred})
+			continue
+		}
+		d, ok, err := describer.DescribeDeployment(ctx, name)
+		res := namespaceResultResponse{Name: name, Replicas: d.Replicas}
+		switch {
+		case err != nil:
+			res.Result, res.Error = resultFailed, err.Error()
+		case !ok:
+			res.Result = resultSkipped // deleted since the listing
+		case op == operationHibernate && d.Excluded():
+			// Waking ignores the label, so labelling a hibernated deployment cannot strand it.
+			res.Result = resultExcluded
+		case op == operationHibernate && d.HibernatedReplicas != nil,
+			op == operationWake && d.HibernatedReplicas == nil:
+			res.Result = resultSkipped
+		default:
+			res = s.applyNamespaceOperation(r.WithContext(ctx), op, d, change, req.BreakGlass, hibernator)
+		}
+
+		switch res.Result {
+		case resultHibernated, resultWoken:
+			resp.Changed++
+		case resultSkipped:
+			resp.Skipped++
+		case resultExcluded:
+			resp.Excluded++
+		default:
+			resp.Failed++
+		}
+		resp.Deployments = append(resp.Deployments, res)
+		slog.InfoContext(ctx, "namespace "+op+" progress", "deployment", name, "result", res.Result, "done", i+1, "total", len(names), "error", res.Error)
+	}
+	if resp.Deferred > 0 {
+		slog.WarnContext(r.Context(), "namespace "+op+" stopped at its deadline", "deferred", resp.Deferred, "total", len(names))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// applyNamespaceOperation hibernates or wakes d, audited as a write by change.Caller.
+// Freeze windows, the PodDisruptionBudget check and the approval rules apply as for a
+// single write, except that a change needing approval is rejected rather than held;
+// breakGlass overrides freeze windows only. The store applies the rest.
+func (s *Server) applyNamespaceOperation(r *http.Request, op string, d kube.Deployment, change kube.Change, breakGlass bool, hibernator kube.Hibernator) namespaceResultResponse {
+	res := namespaceResultResponse{Name: d.Name, Replicas: d.Replicas}
+	var target int32
+	if op == operationWake {
+		target = *d.HibernatedReplicas
+	}
+	event := s.newAuditEvent(r, d.Name, target, change)
+	event.Operation = op
+	reject := func(err error) namespaceResultResponse {
+		event, _ = s.auditAttempt(r, event)
+		s.auditResult(event, err)
+		res.Result, res.Error = resultRejected, err.Error()
+		return res
+	}
+
+	window, frozen, err := s.activeFreeze(r.Context(), d.Name, change.Caller)
+	if err != nil {
+		res.Result, res.Error = resultFailed, err.Error()
+		return res
+	}
+	if frozen {
+		event.FreezeWindow = window.Name
+		if !breakGlass {
+			return reject(&freeze.FrozenError{Window: window.Name})
+		}
+		event.BreakGlass = true
+		slog.WarnContext(r.Context(), "freeze window overridden with break-glass",
+			"window", window.Name, "caller", change.Caller, "deployment", d.Name, "reason", change.Reason)
+	}
+
+	warning, err := s.checkPDB(r.Context(), d.Name, target)
+	if err != nil {
+		return reject(err)
+	}
+	if warning != "" {
+		res.Warning = warning
+		slog.WarnContext(r.Context(), "scale-down blocks poddisruptionbudget evictions", "deployment", d.Name, "replicas", target, "caller", change.Caller, "warning", warning)
+	}
+
+	if s.approvals != nil {
+		if rule, required := s.approvalRules.Requires(d.Replicas, target); required {
+			return reject(fmt.Errorf("%w; scale the deployment on its own", &approval.RequiredError{Rule: rule}))
+		}
+	}
+
+	event, err = s.auditAttempt(r, event)
+	if err != nil {
+		res.Result, res.Error = resultFailed, "audit log unavailable"
+		return res
+	}
+	changed := true
+	switch op {
+	case operationHibernate:
+		changed, err = hibernator.Hibernate(r.Context(), d.Name)
+	case operationWake:
+		if target, err = hibernator.Wake(r.Context(), d.Name); errors.Is(err, kube.ErrNotHibernated) {
+			changed, err = false, nil
+		}
+	}
+	s.auditResult(event, err)
+
+	switch {
+	case err != nil && isRejection(err):
+		res.Result, res.Error = resultRejected, err.Error()
+	case err != nil:
+		res.Result, res.Error = resultFailed, err.Error()
+	case !changed:
+		// Another request got there first.
+		res.Result = resultSkipped
+	default:
+		res.Result = resultHibernated
+		if op == operationWake {
+			res.Result = resultWoken
+		}
+		prev := res.Replicas
+		res.PreviousReplicas = &prev
+		res.Replicas = target
+	}
+	return res
+}
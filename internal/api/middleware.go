@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/logging"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/metrics"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the request ID. Incoming values are propagated; otherwise
+// an ID is generated. Either way it is echoed in the response.
+const requestIDHeader = "X-Request-ID"
+
+// responseRecorder captures the status code and body size written by a handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// logRequests assigns a request ID, stores it in the request context and logs one
+// line per request at level (5xx responses are always logged as errors).
+func logRequests(server string, level slog.Level, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := logging.WithRequestID(r.Context(), id)
+		r = r.WithContext(ctx)
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.statusCode()
+		lvl := level
+		if status >= http.StatusInternalServerError {
+			lvl = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("server", server),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", rec.bytes),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			attrs = append(attrs, slog.String("client_subject", r.TLS.PeerCertificates[0].Subject.String()))
+		}
+		slog.LogAttrs(ctx, lvl, "http request", attrs...)
+	})
+}
+
+// validRequestID accepts caller-supplied IDs of reasonable length made of visible ASCII,
+// so they are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// instrument records request count and latency for every request served by next.
+func instrument(server string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		labels := []string{server, routeLabel(r.URL.Path), r.Method, strconv.Itoa(rec.statusCode())}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeLabel maps a request path to a bounded route template so deployment names
+// never become label values.
+func routeLabel(path string) string {
+	switch path {
+	case "/healthz", "/livez", "/readyz", "/metrics":
+		return path
+	case "/api/v1/deployments", "/api/v1/deployments/":
+		return "/api/v1/deployments"
+	case "/api/v1/budget", "/api/v1/namespace", "/api/v1/namespace:hibernate", "/api/v1/namespace:wake":
+		return path
+	case "/api/v1/schedules", "/api/v1/schedules/":
+		return "/api/v1/schedules"
+	case "/api/v1/changes", "/api/v1/changes/":
+		return "/api/v1/changes"
+	}
+
+	if rest, ok := strings.CutPrefix(path, "/api/v1/changes/"); ok && rest != "" && !strings.Contains(rest, "/") {
+		if _, action, ok := strings.Cut(rest, ":"); ok && (action == "approve" || action == "reject") {
+			return "/api/v1/changes/{id}:" + action
+		}
+		return "/api/v1/changes/{id}"
+	}
+
+	if rest, ok := strings.CutPrefix(path, "/api/v1/deployments/"); ok {
+		parts := strings.Split(strings.Trim(rest, "/"), "/")
+		if len(parts) == 2 && parts[0] != "" && (parts[1] == "replicas" || parts[1] == "history") {
+			return "/api/v1/deployments/{name}/" + parts[1]
+		}
+	}
+	return "other"
+}
+
+// spanName names server spans by method and route template.
+func spanName(r *http.Request) string {
+	return r.Method + " " + routeLabel(r.URL.Path)
+}
+
+// startSpan starts a child span for a handler and returns the request carrying it.
+func startSpan(r *http.Request, name string, attrs ...attribute.KeyValue) (*http.Request, trace.Span) {
+	ctx, span := tracing.Tracer().Start(r.Context(), name, trace.WithAttributes(attrs...))
+	return r.WithContext(ctx), span
+}
+
+// tlsErrorLog is used as http.Server.ErrorLog on the API listener. It counts TLS
+// handshake failures by reason and forwards every message to the standard logger.
+type tlsErrorLog struct{}
+
+func (tlsErrorLog) Write(p []byte) (int, error) {
+	msg := string(p)
+	if strings.Contains(msg, "TLS handshake error") {
+		metrics.TLSHandshakeFailures.WithLabelValues(handshakeFailureReason(msg)).Inc()
+	}
+	slog.Warn(strings.TrimSpace(strings.TrimPrefix(msg, "http: ")), "server", "api")
+	return len(p), nil
+}
+
+// handshakeFailureReason classifies a net/http TLS handshake error message.
+func handshakeFailureReason(msg string) string {
+	switch {
+	case strings.Contains(msg, "has been revoked"):
+		return "revoked_certificate"
+	case strings.Contains(msg, "didn't provide a certificate"), strings.Contains(msg, "certificate required"):
+		return "no_client_certificate"
+	case strings.Contains(msg, "unknown authority"), strings.Contains(msg, "unknown certificate authority"):
+		return "unknown_ca"
+	case strings.Contains(msg, "expired"):
+		return "expired_certificate"
+	case strings.Contains(msg, "bad certificate"), strings.Contains(msg, "failed to verify certificate"):
+		return "bad_certificate"
+	case strings.Contains(msg, "protocol version"):
+		return "protocol_version"
+	case strings.Contains(msg, "no cipher suite"), strings.Contains(msg, "no mutual cipher"):
+		return "no_shared_cipher"
+	case strings.Contains(msg, "first record does not look like a TLS handshake"):
+		return "not_tls"
+	case strings.Contains(msg, "EOF"), strings.Contains(msg, "connection reset"):
+		return "eof"
+	default:
+		return "other"
+	}
+}
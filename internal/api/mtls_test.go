@@ -12,6 +12,9 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -124,6 +127,336 @@ func TestAPIMTLSRejectsMissingClientCert(t *testing.T) {
 	}
 }
 
+func TestAPIMTLSRejectsRevokedClientCert(t *testing.T) {
+	ca, serverCert, clientCert, _, roots := mustMakeTestPKI(t)
+
+	// start with an empty CRL, then revoke the client cert and reload
+	crlFile := filepath.Join(t.TempDir(), "client.crl")
+	mustWriteCRL(t, crlFile, ca, 1)
+
+	crl, err := newCRLChecker([]string{crlFile}, []*x509.Certificate{ca.cert})
+	if err != nil {
+		t.Fatalf("load crl: %v", err)
+	}
+
+	s := New(config.Config{ListenAddr: ":0", ProbeListenAddr: ":0", TLSEnabled: true}, readyStore{})
+
+	apiMux := http.NewServeMux()
+	apiMux.HandleFunc("/api/v1/", s.routeAPIv1)
+
+	ts := httptest.NewUnstartedServer(apiMux)
+	ts.TLS = &tls.Config{
+		MinVersion:            tls.VersionTLS12,
+		Certificates:          []tls.Certificate{serverCert},
+		ClientCAs:             ca.pool,
+		ClientAuth:            tls.RequireAndVerifyClientCert,
+		VerifyPeerCertificate: crl.verifyPeerCertificate,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	newClient := func() *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      roots,
+					Certificates: []tls.Certificate{clientCert},
+				},
+				DisableKeepAlives: true,
+			},
+			Timeout: 2 * time.Second,
+		}
+	}
+
+	resp, err := newClient().Get(ts.URL + "/api/v1/deployments")
+	if err != nil {
+		t.Fatalf("expected success before revocation, got %v", err)
+	}
+	resp.Body.Close()
+
+	mustWriteCRL(t, crlFile, ca, 2, clientCert.Leaf.SerialNumber)
+	if err := crl.reload(); err != nil {
+		t.Fatalf("reload crl: %v", err)
+	}
+
+	_, err = newClient().Get(ts.URL + "/api/v1/deployments")
+	if err == nil {
+		t.Fatalf("expected TLS handshake failure with revoked client cert, got nil error")
+	}
+}
+
+func TestCRLMustBeSignedByClientCA(t *testing.T) {
+	ca, _, _, _, _ := mustMakeTestPKI(t)
+	other, _, _, _, _ := mustMakeTestPKI(t)
+
+	crlFile := filepath.Join(t.TempDir(), "client.crl")
+	mustWriteCRL(t, crlFile, other, 1)
+
+	if _, err := newCRLChecker([]string{crlFile}, []*x509.Certificate{ca.cert}); err == nil {
+		t.Fatalf("expected error for CRL signed by an untrusted CA")
+	}
+}
+
+func TestConfigValidateTLSPolicy(t *testing.T) {
+	base := config.Config{
+		TLSEnabled:      true,
+		TLSCertFile:     "server.crt",
+		TLSKeyFile:      "server.key",
+		TLSClientCAFile: "ca.crt",
+		TLSMinVersion:   "1.2",
+		TLSClientAuth:   config.ClientAuthRequire,
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*config.Config)
+		wantErr string
+	}{
+		{name: "defaults", mutate: func(c *config.Config) {}},
+		{name: "tls13 only", mutate: func(c *config.Config) { c.TLSMinVersion, c.TLSMaxVersion = "1.3", "1.3" }},
+		{name: "tls12 suites and curves", mutate: func(c *config.Config) {
+			c.TLSCipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+			c.TLSCurvePreferences = []string{"X25519", "P256"}
+		}},
+		{name: "verify if given", mutate: func(c *config.Config) { c.TLSClientAuth = config.ClientAuthVerifyIfGiven }},
+		{name: "unknown version", mutate: func(c *config.Config) { c.TLSMinVersion = "1.1" }, wantErr: "TLS_MIN_VERSION"},
+		{name: "min above max", mutate: func(c *config.Config) { c.TLSMinVersion, c.TLSMaxVersion = "1.3", "1.2" }, wantErr: "greater than TLS_MAX_VERSION"},
+		{name: "unknown suite", mutate: func(c *config.Config) { c.TLSCipherSuites = []string{"TLS_NOPE"} }, wantErr: "unknown cipher suite"},
+		{name: "insecure suite", mutate: func(c *config.Config) { c.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} }, wantErr: "insecure"},
+		{name: "tls13 suite", mutate: func(c *config.Config) { c.TLSCipherSuites = []string{"TLS_AES_128_GCM_SHA256"} }, wantErr: "not configurable"},
+		{name: "suites with tls13 min", mutate: func(c *config.Config) {
+			c.TLSMinVersion = "1.3"
+			c.TLSCipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+		}, wantErr: "no effect"},
+		{name: "unknown curve", mutate: func(c *config.Config) { c.TLSCurvePreferences = []string{"P224"} }, wantErr: "unknown curve"},
+		{name: "unknown client auth", mutate: func(c *config.Config) { c.TLSClientAuth = "optional" }, wantErr: "TLS_CLIENT_AUTH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBuildTLSConfigAppliesPolicy(t *testing.T) {
+	ca, serverCert, _, _, _ := mustMakeTestPKI(t)
+	certFile, keyFile, caFile := mustWriteServerPKI(t, ca, serverCert)
+
+	s := New(config.Config{
+		ListenAddr:          ":0",
+		ProbeListenAddr:     ":0",
+		TLSEnabled:          true,
+		TLSCertFile:         certFile,
+		TLSKeyFile:          keyFile,
+		TLSClientCAFile:     caFile,
+		TLSMinVersion:       "1.2",
+		TLSMaxVersion:       "1.2",
+		TLSCipherSuites:     []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		TLSCurvePreferences: []string{"P384"},
+		TLSClientAuth:       config.ClientAuthVerifyIfGiven,
+	}, readyStore{})
+
+	tlsCfg, err := s.buildTLSConfig()
+	if err != nil {
+		t.Fatalf("build tls config: %v", err)
+	}
+	if tlsCfg.MinVersion != tls.VersionTLS12 || tlsCfg.MaxVersion != tls.VersionTLS12 {
+		t.Fatalf("unexpected versions min=%x max=%x", tlsCfg.MinVersion, tlsCfg.MaxVersion)
+	}
+	if len(tlsCfg.CipherSuites) != 1 || tlsCfg.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Fatalf("unexpected cipher suites %v", tlsCfg.CipherSuites)
+	}
+	if len(tlsCfg.CurvePreferences) != 1 || tlsCfg.CurvePreferences[0] != tls.CurveP384 {
+		t.Fatalf("unexpected curves %v", tlsCfg.CurvePreferences)
+	}
+	if tlsCfg.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Fatalf("unexpected client auth %v", tlsCfg.ClientAuth)
+	}
+}
+
+func TestAPIVerifyIfGivenAcceptsMissingClientCert(t *testing.T) {
+	ca, serverCert, _, badClientCert, roots := mustMakeTestPKI(t)
+	certFile, keyFile, caFile := mustWriteServerPKI(t, ca, serverCert)
+
+	s := New(config.Config{
+		ListenAddr:      ":0",
+		ProbeListenAddr: ":0",
+		TLSEnabled:      true,
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: caFile,
+		TLSClientAuth:   config.ClientAuthVerifyIfGiven,
+	}, readyStore{})
+
+	tlsCfg, err := s.buildTLSConfig()
+	if err != nil {
+		t.Fatalf("build tls config: %v", err)
+	}
+
+	apiMux := http.NewServeMux()
+	apiMux.HandleFunc("/api/v1/", s.routeAPIv1)
+
+	ts := httptest.NewUnstartedServer(apiMux)
+	ts.TLS = tlsCfg
+	ts.StartTLS()
+	defer ts.Close()
+
+	noCertClient := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+		Timeout:   2 * time.Second,
+	}
+	resp, err := noCertClient.Get(ts.URL + "/api/v1/deployments")
+	if err != nil {
+		t.Fatalf("expected success without client cert, got %v", err)
+	}
+	resp.Body.Close()
+
+	// a presented certificate must still verify; force sending it even though
+	// its issuer is not in the server's acceptable CA list
+	badClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: roots,
+				GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					return &badClientCert, nil
+				},
+			},
+		},
+		Timeout: 2 * time.Second,
+	}
+	if _, err := badClient.Get(ts.URL + "/api/v1/deployments"); err == nil {
+		t.Fatalf("expected TLS handshake failure with wrong client cert, got nil error")
+	}
+}
+
+func TestAPIVerifyIfGivenMissingClientCertCannotWrite(t *testing.T) {
+	ca, serverCert, clientCert, _, roots := mustMakeTestPKI(t)
+	certFile, keyFile, caFile := mustWriteServerPKI(t, ca, serverCert)
+
+	s := New(config.Config{
+		ListenAddr:      ":0",
+		ProbeListenAddr: ":0",
+		TLSEnabled:      true,
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: caFile,
+		TLSClientAuth:   config.ClientAuthVerifyIfGiven,
+	}, readyStore{})
+
+	tlsCfg, err := s.buildTLSConfig()
+	if err != nil {
+		t.Fatalf("build tls config: %v", err)
+	}
+
+	apiMux := http.NewServeMux()
+	apiMux.HandleFunc("/api/v1/", s.routeAPIv1)
+
+	ts := httptest.NewUnstartedServer(apiMux)
+	ts.TLS = tlsCfg
+	ts.StartTLS()
+	defer ts.Close()
+
+	post := func(certs ...tls.Certificate) int {
+		t.Helper()
+		c := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}},
+			Timeout:   2 * time.Second,
+		}
+		resp, err := c.Post(ts.URL+"/api/v1/deployments/demo/replicas", "application/json", strings.NewReader(`{"replicas":2}`))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post(); code != http.StatusForbidden {
+		t.Fatalf("POST without client cert expected 403 got %d", code)
+	}
+	if code := post(clientCert); code != http.StatusOK {
+		t.Fatalf("POST with client cert expected 200 got %d", code)
+	}
+}
+
+func TestAPIReadOnlyClientCACannotWrite(t *testing.T) {
+	ca, serverCert, rwClientCert, _, roots := mustMakeTestPKI(t)
+	roCA, _, roClientCert, _, _ := mustMakeTestPKI(t)
+	certFile, keyFile, caFile := mustWriteServerPKI(t, ca, serverCert)
+
+	roCAFile := filepath.Join(t.TempDir(), "readonly-ca.crt")
+	if err := os.WriteFile(roCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: roCA.cert.Raw}), 0o600); err != nil {
+		t.Fatalf("write read-only ca: %v", err)
+	}
+
+	s := New(config.Config{
+		ListenAddr:              ":0",
+		ProbeListenAddr:         ":0",
+		TLSEnabled:              true,
+		TLSCertFile:             certFile,
+		TLSKeyFile:              keyFile,
+		TLSClientCAFile:         caFile,
+		TLSReadOnlyClientCAFile: roCAFile,
+	}, readyStore{})
+
+	tlsCfg, err := s.buildTLSConfig()
+	if err != nil {
+		t.Fatalf("build tls config: %v", err)
+	}
+
+	apiMux := http.NewServeMux()
+	apiMux.HandleFunc("/api/v1/", s.routeAPIv1)
+
+	ts := httptest.NewUnstartedServer(apiMux)
+	ts.TLS = tlsCfg
+	ts.StartTLS()
+	defer ts.Close()
+
+	clientFor := func(cert tls.Certificate) *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}},
+			},
+			Timeout: 2 * time.Second,
+		}
+	}
+
+	do := func(c *http.Client, method string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1/deployments/demo/replicas", strings.NewReader(`{"replicas":2}`))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	ro := clientFor(roClientCert)
+	if code := do(ro, http.MethodGet); code != http.StatusOK {
+		t.Fatalf("read-only GET expected 200 got %d", code)
+	}
+	if code := do(ro, http.MethodPost); code != http.StatusForbidden {
+		t.Fatalf("read-only POST expected 403 got %d", code)
+	}
+
+	rw := clientFor(rwClientCert)
+	if code := do(rw, http.MethodPost); code != http.StatusOK {
+		t.Fatalf("read-write POST expected 200 got %d", code)
+	}
+}
+
 // ---- PKI helpers (self-contained) ----
 
 type testCA struct {
@@ -214,6 +547,55 @@ func mustMakeTestPKI(t *testing.T) (ca testCA, server tls.Certificate, client tl
 	return
 }
 
+// mustWriteServerPKI writes the server cert/key and client CA as PEM files for buildTLSConfig.
+func mustWriteServerPKI(t *testing.T, ca testCA, server tls.Certificate) (certFile, keyFile, caFile string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(server.PrivateKey)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	caFile = filepath.Join(dir, "ca.crt")
+
+	files := map[string][]byte{
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate[0]}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		caFile:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}),
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	return certFile, keyFile, caFile
+}
+
+func mustWriteCRL(t *testing.T, path string, ca testCA, number int64, revoked ...*big.Int) {
+	t.Helper()
+	tpl := &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, serial := range revoked {
+		tpl.RevokedCertificateEntries = append(tpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tpl, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("create crl: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write crl: %v", err)
+	}
+}
+
 func mustRSA(t *testing.T, bits int) *rsa.PrivateKey {
 	t.Helper()
 	k, err := rsa.GenerateKey(rand.Reader, bits)
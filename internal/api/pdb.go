@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/config"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+// checkPDB checks scaling name to replicas against the PodDisruptionBudget covering it.
+// A scale-down that would leave the PDB allowing no disruptions returns a
+// *kube.PDBError in config.PDBModeReject, or a warning for the response in
+// config.PDBModeWarn.
+func (s *Server) checkPDB(ctx context.Context, name string, replicas int32) (string, error) {
+	if s.cfg.PDBMode != config.PDBModeWarn && s.cfg.PDBMode != config.PDBModeReject {
+		return "", nil
+	}
+	describer, ok := s.store.(kube.Describer)
+	if !ok {
+		return "", nil
+	}
+	d, ok, err := describer.DescribeDeployment(ctx, name)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	err = d.CheckPDB(replicas)
+	var pdbErr *kube.PDBError
+	if s.cfg.PDBMode == config.PDBModeWarn && errors.As(err, &pdbErr) {
+		return pdbErr.Error(), nil
+	}
+	return "", err
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+const (
+	rejectReasonRead     = "rate_limit_read"
+	rejectReasonWrite    = "rate_limit_write"
+	rejectReasonInFlight = "in_flight"
+)
+
+const (
+	limiterIdleTTL       = 10 * time.Minute
+	limiterSweepInterval = time.Minute
+)
+
+// rateLimiter applies per-client token buckets (separate for reads and writes) and a
+// global cap on concurrent requests.
+type rateLimiter struct {
+	readLimit  rate.Limit
+	readBurst  int
+	writeLimit rate.Limit
+	writeBurst int
+
+	// inFlight is a semaphore; nil means no concurrency cap.
+	inFlight chan struct{}
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiters
+	lastSweep time.Time
+}
+
+type clientLimiters struct {
+	read     *rate.Limiter
+	write    *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter returns nil when every limit is disabled (0).
+func newRateLimiter(readRPS float64, readBurst int, writeRPS float64, writeBurst int, maxInFlight int) *rateLimiter {
+	if readRPS <= 0 && writeRPS <= 0 && maxInFlight <= 0 {
+		return nil
+	}
+
+	l := &rateLimiter{
+		readLimit:  toLimit(readRPS),
+		readBurst:  readBurst,
+		writeLimit: toLimit(writeRPS),
+		writeBurst: writeBurst,
+		clients:    make(map[string]*clientLimiters),
+	}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// toLimit maps a non-positive rate to "unlimited".
+func toLimit(rps float64) rate.Limit {
+	if rps <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(rps)
+}
+
+// limiterFor returns the read or write bucket for a client, creating it on first use.
+func (l *rateLimiter) limiterFor(client string, write bool, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		for id, c := range l.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTTL {
+				delete(l.clients, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiters{
+			read:  rate.NewLimiter(l.readLimit, l.readBurst),
+			write: rate.NewLimiter(l.writeLimit, l.writeBurst),
+		}
+		l.clients[client] = c
+	}
+	c.lastSeen = now
+
+	if write {
+		return c.write
+	}
+	return c.read
+}
+
+// limitRequests wraps the API handler with the configured limits.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	l := s.limiter
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.inFlight != nil {
+			select {
+			case l.inFlight <- struct{}{}:
+				defer func() { <-l.inFlight }()
+			default:
+				s.auditRefusal(r, audit.ResultDenied, "too many requests in flight")
+				rejectTooManyRequests(w, rejectReasonInFlight, time.Second)
+				return
+			}
+		}
+
+		write := !isReadOnlyMethod(r.Method)
+		now := time.Now()
+		res := l.limiterFor(clientIdentity(r), write, now).ReserveN(now, 1)
+		if !res.OK() {
+			// burst of 0 with a finite rate: the request can never be admitted
+			s.auditRefusal(r, audit.ResultDenied, "rate limit exceeded")
+			rejectTooManyRequests(w, limitReason(write), time.Second)
+			return
+		}
+		if delay := res.DelayFrom(now); delay > 0 {
+			res.CancelAt(now)
+			s.auditRefusal(r, audit.ResultDenied, "rate limit exceeded")
+			rejectTooManyRequests(w, limitReason(write), delay)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func limitReason(write bool) string {
+	if write {
+		return rejectReasonWrite
+	}
+	return rejectReasonRead
+}
+
+func rejectTooManyRequests(w http.ResponseWriter, reason string, retryAfter time.Duration) {
+	metrics.RejectedRequests.WithLabelValues(reason).Inc()
+
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeJSON(w, http.StatusTooManyRequests, map[string]any{
+		"error": "too many requests",
+	})
+}
@@ -5,15 +5,27 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/config"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/history"
 	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/metrics"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/scheduler"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/tracing"
 )
 
 // Server wraps an HTTP server and exposes lifecycle helpers for starting and shutting down.
@@ -21,14 +33,89 @@ type Server struct {
 	cfg      config.Config
 	apiSrv   *http.Server
 	probeSrv *http.Server
+	// adminSrv is nil unless cfg.AdminListenAddr is set.
+	adminSrv *http.Server
 	store    kube.Store
+
+	// crl is set when client CRL files are configured and TLS is enabled.
+	crl *crlChecker
+
+	// limiter is nil when all request limits are disabled.
+	limiter *rateLimiter
+
+	// Client CA bundles by trust domain, populated by buildTLSConfig.
+	readWriteCAs caSet
+	readOnlyCAs  caSet
+
+	// ping caches Kubernetes connectivity results for /readyz.
+	ping *pingCache
+	// serverCert is the loaded server certificate leaf, set by buildTLSConfig.
+	serverCert atomic.Pointer[x509.Certificate]
+
+	// auditor records write operations; nil disables auditing.
+	auditor *audit.Logger
+	// history serves replica change history; nil disables the history endpoint.
+	history *history.Recorder
+	// scheduler reports scheduled scaling rules; nil serves an empty list.
+	scheduler *scheduler.Scheduler
+	// freezes refuses writes during freeze windows; nil disables freezes.
+	freezes *freeze.Calendar
+	// approvals holds writes matching approvalRules for a second approver; nil disables
+	// approvals.
+	approvals     *approval.Queue
+	approvalRules approval.Rules
+
+	// stopCh stops background goroutines (e.g. CRL reloads) on Shutdown.
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Option configures optional Server dependencies.
+type Option func(*Server)
+
+// WithAuditLogger records every write operation to l.
+func WithAuditLogger(l *audit.Logger) Option {
+	return func(s *Server) { s.auditor = l }
+}
+
+// WithHistory serves replica change history from h.
+func WithHistory(h *history.Recorder) Option {
+	return func(s *Server) { s.history = h }
+}
+
+// WithFreezeCalendar refuses writes during the windows in c.
+func WithFreezeCalendar(c *freeze.Calendar) Option {
+	return func(s *Server) { s.freezes = c }
+}
+
+// WithApprovals holds writes matching rules in q until another identity approves them.
+func WithApprovals(q *approval.Queue, rules approval.Rules) Option {
+	return func(s *Server) {
+		s.approvals = q
+		s.approvalRules = rules
+	}
+}
+
+// WithScheduler reports the status of scheduled scaling rules from sch.
+func WithScheduler(sch *scheduler.Scheduler) Option {
+	return func(s *Server) { s.scheduler = sch }
 }
 
 // New constructs a Server with routes registered.
-func New(cfg config.Config, store kube.Store) *Server {
+func New(cfg config.Config, store kube.Store, opts ...Option) *Server {
 	s := &Server{
-		cfg:   cfg,
-		store: store,
+		cfg:    cfg,
+		store:  store,
+		stopCh: make(chan struct{}),
+		ping:   &pingCache{ttl: cfg.ReadyzPingTTL},
+		limiter: newRateLimiter(
+			cfg.RateLimitReadRPS, cfg.RateLimitReadBurst,
+			cfg.RateLimitWriteRPS, cfg.RateLimitWriteBurst,
+			cfg.MaxInFlight,
+		),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 
 	// API mux: only API routes (will be HTTPS+mTLS when enabled)
@@ -37,7 +124,8 @@ func New(cfg config.Config, store kube.Store) *Server {
 
 	s.apiSrv = &http.Server{
 		Addr:              cfg.ListenAddr,
-		Handler:           apiMux,
+		Handler:           tracing.Handler(logRequests("api", slog.LevelInfo, instrument("api", s.limitRequests(apiMux))), spanName),
+		ErrorLog:          log.New(tlsErrorLog{}, "", 0),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       10 * time.Second,
 		WriteTimeout:      10 * time.Second,
@@ -48,16 +136,28 @@ func New(cfg config.Config, store kube.Store) *Server {
 	probeMux := http.NewServeMux()
 	probeMux.HandleFunc("/healthz", s.handleHealthz)
 	probeMux.HandleFunc("/readyz", s.handleReadyz)
+	probeMux.HandleFunc("/livez", s.handleLivez)
+	probeMux.Handle("/metrics", metrics.Handler())
 
 	s.probeSrv = &http.Server{
 		Addr:              cfg.ProbeListenAddr,
-		Handler:           probeMux,
+		Handler:           logRequests("probe", slog.LevelDebug, instrument("probe", probeMux)),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       5 * time.Second,
 		WriteTimeout:      5 * time.Second,
 		IdleTimeout:       30 * time.Second,
 	}
 
+	if cfg.AdminListenAddr != "" {
+		// No WriteTimeout: CPU profiles and traces stream for as long as requested.
+		s.adminSrv = &http.Server{
+			Addr:              cfg.AdminListenAddr,
+			Handler:           logRequests("admin", slog.LevelInfo, s.newAdminMux()),
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		}
+	}
+
 	return s
 }
 
@@ -66,23 +166,111 @@ func (s *Server) buildTLSConfig() (*tls.Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load server cert/key: %w", err)
 	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse server cert: %w", err)
+	}
 
-	caPEM, err := os.ReadFile(s.cfg.TLSClientCAFile)
+	caCerts, err := loadCerts(s.cfg.TLSClientCAFile)
 	if err != nil {
 		return nil, fmt.Errorf("read client CA: %w", err)
 	}
 
 	pool := x509.NewCertPool()
-	if !pool.AppendCertsFromPEM(caPEM) {
-		return nil, fmt.Errorf("parse client CA: no certs found")
+	for _, c := range caCerts {
+		pool.AddCert(c)
 	}
+	s.readWriteCAs = newCASet(caCerts)
 
-	return &tls.Config{
-		MinVersion:   tls.VersionTLS12,
-		Certificates: []tls.Certificate{cert},
-		ClientCAs:    pool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-	}, nil
+	// CRLs may be issued by either trust domain.
+	issuers := caCerts
+	if s.cfg.TLSReadOnlyClientCAFile != "" {
+		roCerts, err := loadCerts(s.cfg.TLSReadOnlyClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read read-only client CA: %w", err)
+		}
+		for _, c := range roCerts {
+			pool.AddCert(c)
+		}
+		s.readOnlyCAs = newCASet(roCerts)
+		issuers = append(append([]*x509.Certificate(nil), caCerts...), roCerts...)
+	}
+
+	// Policy values are validated by config.Load; errors here mean the Config was built by hand.
+	minVersion, err := config.ParseTLSVersion(s.cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	maxVersion, err := config.ParseTLSVersion(s.cfg.TLSMaxVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := config.ParseCipherSuites(s.cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	curves, err := config.ParseCurvePreferences(s.cfg.TLSCurvePreferences)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := config.ParseClientAuth(s.cfg.TLSClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:       minVersion,
+		MaxVersion:       maxVersion,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: curves,
+		Certificates:     []tls.Certificate{cert},
+		ClientCAs:        pool,
+		ClientAuth:       clientAuth,
+	}
+
+	if len(s.cfg.TLSClientCRLFiles) > 0 {
+		crl, err := newCRLChecker(s.cfg.TLSClientCRLFiles, issuers)
+		if err != nil {
+			return nil, fmt.Errorf("load client CRLs: %w", err)
+		}
+		s.crl = crl
+		tlsCfg.VerifyPeerCertificate = crl.verifyPeerCertificate
+	}
+
+	s.serverCert.Store(leaf)
+	return tlsCfg, nil
+}
+
+// loadCerts reads all PEM encoded certificates from file.
+func loadCerts(file string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certs found")
+	}
+	return certs, nil
 }
 
 // Start begins serving HTTP requests and blocks until the server stops.
@@ -93,19 +281,32 @@ func (s *Server) Start() error {
 		return err
 	}
 	go func() {
-		log.Printf("probe listening on %s", s.cfg.ProbeListenAddr)
+		slog.Info("probe listening", "addr", s.cfg.ProbeListenAddr)
 		if err := s.probeSrv.Serve(probeLn); err != nil && err != http.ErrServerClosed {
-			log.Printf("probe server error: %v", err)
+			slog.Error("probe server error", "error", err)
 		}
 	}()
 
+	if s.adminSrv != nil {
+		adminLn, err := net.Listen("tcp", s.cfg.AdminListenAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			slog.Warn("admin debug endpoints listening without authentication", "addr", s.cfg.AdminListenAddr)
+			if err := s.adminSrv.Serve(adminLn); err != nil && err != http.ErrServerClosed {
+				slog.Error("admin server error", "error", err)
+			}
+		}()
+	}
+
 	// Start API server.
 	apiLn, err := net.Listen("tcp", s.cfg.ListenAddr)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("api listening on %s (tls=%v namespace=%s)", s.cfg.ListenAddr, s.cfg.TLSEnabled, s.cfg.Namespace)
+	slog.Info("api listening", "addr", s.cfg.ListenAddr, "tls", s.cfg.TLSEnabled, "namespace", s.cfg.Namespace)
 
 	if !s.cfg.TLSEnabled {
 		return s.apiSrv.Serve(apiLn)
@@ -117,18 +318,25 @@ func (s *Server) Start() error {
 	}
 	s.apiSrv.TLSConfig = tlsCfg
 
+	if s.crl != nil {
+		go s.crl.watch(s.cfg.TLSCRLReloadInterval, s.stopCh)
+	}
+
 	// Certificates are in TLSConfig, so pass empty filenames.
 	return s.apiSrv.ServeTLS(apiLn, "", "")
 }
 
-// Shutdown gracefully stops both servers.
+// Shutdown gracefully stops all servers.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+
 	err1 := s.apiSrv.Shutdown(ctx)
 	err2 := s.probeSrv.Shutdown(ctx)
-	if err1 != nil {
-		return err1
+	var err3 error
+	if s.adminSrv != nil {
+		err3 = s.adminSrv.Shutdown(ctx)
 	}
-	return err2
+	return errors.Join(err1, err2, err3)
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -137,6 +345,6 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 
 	if err := json.NewEncoder(w).Encode(v); err != nil {
 		// Can't reliably change the status code here since headers may already be written.
-		log.Printf("write json response: %v", err)
+		slog.Error("write json response", "error", err)
 	}
 }
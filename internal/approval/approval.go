@@ -0,0 +1,253 @@
+// Package approval holds risky scale changes until a second identity approves them.
+package approval
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// States of a pending change.
+const (
+	StatePending  = "pending"
+	StateApproved = "approved"
+	StateRejected = "rejected"
+	StateExpired  = "expired"
+	StateApplied  = "applied"
+	StateFailed   = "failed"
+)
+
+// Errors returned by Queue decisions.
+var (
+	ErrNotFound     = errors.New("change not found")
+	ErrSelfApproval = errors.New("a change cannot be decided by the identity that requested it")
+)
+
+// NotPendingError reports a decision on a change that is no longer pending.
+type NotPendingError struct {
+	State string
+}
+
+func (e *NotPendingError) Error() string {
+	return fmt.Sprintf("change is %s", e.State)
+}
+
+// RequiredError refuses a change that Rules would hold for a second approver, where
+// the change cannot wait for one, such as a scheduled run.
+type RequiredError struct {
+	Rule string
+}
+
+func (e *RequiredError) Error() string {
+	return fmt.Sprintf("change requires approval (%s)", e.Rule)
+}
+
+// Rules select the writes that need a second approver.
+type Rules struct {
+	// ScaleToZero requires approval for any change to 0 replicas.
+	ScaleToZero bool
+	// MaxChangePercent requires approval when replicas change by more than this
+	// percentage of the current count; 0 disables the rule. Scaling up from 0 is not
+	// covered, as there is no base to compare against.
+	MaxChangePercent int
+}
+
+// Enabled reports whether any rule is configured.
+func (r Rules) Enabled() bool {
+	return r.ScaleToZero || r.MaxChangePercent > 0
+}
+
+// Requires reports whether changing from prev to replicas needs approval, and why.
+func (r Rules) Requires(prev, replicas int32) (string, bool) {
+	if replicas == prev {
+		return "", false
+	}
+	if r.ScaleToZero && replicas == 0 {
+		return "scale to zero", true
+	}
+	if r.MaxChangePercent > 0 && prev > 0 {
+		delta := int64(replicas) - int64(prev)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta*100 > int64(prev)*int64(r.MaxChangePercent) {
+			return fmt.Sprintf("change of more than %d%%", r.MaxChangePercent), true
+		}
+	}
+	return "", false
+}
+
+// Change is a scale request awaiting, or past, a decision.
+type Change struct {
+	ID               string    `json:"id"`
+	Deployment       string    `json:"deployment"`
+	Replicas         int32     `json:"replicas"`
+	PreviousReplicas int32     `json:"previousReplicas"`
+	Rule             string    `json:"rule"`
+	RequestedBy      string    `json:"requestedBy"`
+	Reason           string    `json:"reason,omitempty"`
+	Ticket           string    `json:"ticket,omitempty"`
+	HPAOverride      bool      `json:"hpaOverride,omitempty"`
+	BreakGlass       bool      `json:"breakGlass,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	State            string    `json:"state"`
+	DecidedBy        string    `json:"decidedBy,omitempty"`
+	DecidedAt        time.Time `json:"decidedAt,omitzero"`
+	DecisionReason   string    `json:"decisionReason,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// Queue keeps changes in memory. Pending changes expire after the TTL; decided and
+// expired changes are kept for another TTL so their outcome can be looked up.
+type Queue struct {
+	ttl   time.Duration
+	clock clock.PassiveClock
+
+	mu      sync.Mutex
+	changes map[string]*Change
+}
+
+// NewQueue returns a Queue whose changes expire after ttl. A nil clk uses the real clock.
+func NewQueue(ttl time.Duration, clk clock.PassiveClock) *Queue {
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	return &Queue{ttl: ttl, clock: clk, changes: map[string]*Change{}}
+}
+
+// Submit records a pending change and returns it with its ID and expiry set.
+func (q *Queue) Submit(c Change) Change {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+	q.prune(now)
+
+	c.ID = newID()
+	c.State = StatePending
+	c.CreatedAt = now
+	c.ExpiresAt = now.Add(q.ttl)
+	q.changes[c.ID] = &c
+	return c
+}
+
+// Get returns a change by ID.
+func (q *Queue) Get(id string) (Change, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+	q.prune(now)
+
+	c, ok := q.changes[id]
+	if !ok {
+		return Change{}, false
+	}
+	q.expire(c, now)
+	return *c, true
+}
+
+// List returns all known changes, oldest first.
+func (q *Queue) List() []Change {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+	q.prune(now)
+
+	out := make([]Change, 0, len(q.changes))
+	for _, c := range q.changes {
+		q.expire(c, now)
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Approve moves a pending change to approved on behalf of identity. The caller applies
+// it and reports the outcome with Complete.
+func (q *Queue) Approve(id, identity string) (Change, error) {
+	return q.decide(id, identity, StateApproved, "")
+}
+
+// Reject moves a pending change to rejected on behalf of identity.
+func (q *Queue) Reject(id, identity, reason string) (Change, error) {
+	return q.decide(id, identity, StateRejected, reason)
+}
+
+// Complete records the outcome of applying an approved change.
+func (q *Queue) Complete(id string, err error) Change {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c, ok := q.changes[id]
+	if !ok {
+		return Change{}
+	}
+	c.State = StateApplied
+	if err != nil {
+		c.State = StateFailed
+		c.Error = err.Error()
+	}
+	return *c
+}
+
+func (q *Queue) decide(id, identity, state, reason string) (Change, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+
+	c, ok := q.changes[id]
+	if !ok {
+		return Change{}, ErrNotFound
+	}
+	q.expire(c, now)
+	if c.State != StatePending {
+		return *c, &NotPendingError{State: c.State}
+	}
+	if identity == c.RequestedBy {
+		return *c, ErrSelfApproval
+	}
+
+	c.State = state
+	c.DecidedBy = identity
+	c.DecidedAt = now
+	c.DecisionReason = reason
+	return *c, nil
+}
+
+// expire marks c expired once its TTL has passed.
+func (q *Queue) expire(c *Change, now time.Time) {
+	if c.State == StatePending && !now.Before(c.ExpiresAt) {
+		c.State = StateExpired
+	}
+}
+
+// prune drops changes that expired or were decided more than a TTL ago.
+func (q *Queue) prune(now time.Time) {
+	for id, c := range q.changes {
+		q.expire(c, now)
+		settled := c.ExpiresAt
+		if !c.DecidedAt.IsZero() {
+			settled = c.DecidedAt
+		}
+		if c.State != StatePending && c.State != StateApproved && now.Sub(settled) > q.ttl {
+			delete(q.changes, id)
+		}
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
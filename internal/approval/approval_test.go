@@ -0,0 +1,90 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestRulesRequires(t *testing.T) {
+	rules := Rules{ScaleToZero: true, MaxChangePercent: 50}
+	cases := []struct {
+		prev, replicas int32
+		want           bool
+	}{
+		{4, 0, true},
+		{4, 6, false}, // exactly 50%
+		{4, 7, true},
+		{4, 2, false},
+		{4, 1, true},
+		{0, 10, false},
+		{3, 3, false},
+	}
+	for _, tc := range cases {
+		if _, got := rules.Requires(tc.prev, tc.replicas); got != tc.want {
+			t.Errorf("Requires(%d, %d) = %v, want %v", tc.prev, tc.replicas, got, tc.want)
+		}
+	}
+	if _, got := (Rules{}).Requires(4, 0); got {
+		t.Error("disabled rules must not require approval")
+	}
+}
+
+func TestQueueDecisions(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	q := NewQueue(time.Hour, clk)
+
+	c := q.Submit(Change{Deployment: "web", Replicas: 0, PreviousReplicas: 4, RequestedBy: "alice"})
+	if c.ID == "" || c.State != StatePending || !c.ExpiresAt.Equal(clk.Now().Add(time.Hour)) {
+		t.Fatalf("submitted = %+v", c)
+	}
+
+	if _, err := q.Approve(c.ID, "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("self approval: got %v", err)
+	}
+	if _, err := q.Approve("missing", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing: got %v", err)
+	}
+
+	approved, err := q.Approve(c.ID, "bob")
+	if err != nil || approved.State != StateApproved || approved.DecidedBy != "bob" {
+		t.Fatalf("approve = %+v, %v", approved, err)
+	}
+	var notPending *NotPendingError
+	if _, err := q.Reject(c.ID, "carol", ""); !errors.As(err, &notPending) || notPending.State != StateApproved {
+		t.Fatalf("second decision: got %v", err)
+	}
+	if done := q.Complete(c.ID, nil); done.State != StateApplied {
+		t.Fatalf("complete = %+v", done)
+	}
+
+	rejected := q.Submit(Change{Deployment: "api", RequestedBy: "alice"})
+	if got, err := q.Reject(rejected.ID, "bob", "not now"); err != nil || got.State != StateRejected || got.DecisionReason != "not now" {
+		t.Fatalf("reject = %+v, %v", got, err)
+	}
+}
+
+func TestQueueExpiresAndPrunes(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	q := NewQueue(time.Hour, clk)
+	c := q.Submit(Change{Deployment: "web", RequestedBy: "alice"})
+
+	clk.SetTime(clk.Now().Add(time.Hour))
+	if got, _ := q.Get(c.ID); got.State != StateExpired {
+		t.Fatalf("state = %s, want expired", got.State)
+	}
+	var notPending *NotPendingError
+	if _, err := q.Approve(c.ID, "bob"); !errors.As(err, &notPending) || notPending.State != StateExpired {
+		t.Fatalf("approve expired: got %v", err)
+	}
+
+	clk.SetTime(clk.Now().Add(time.Hour + time.Second))
+	if _, ok := q.Get(c.ID); ok {
+		t.Fatal("expected expired change to be pruned")
+	}
+	if len(q.List()) != 0 {
+		t.Fatalf("list = %+v", q.List())
+	}
+}
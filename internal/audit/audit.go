@@ -0,0 +1,156 @@
+// Package audit records every write operation to one or more pluggable sinks.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sink names accepted by AUDIT_SINKS.
+const (
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+)
+
+// Stages of a write. Each write produces an attempt record before the change is applied
+// and a result record afterwards.
+const (
+	StageAttempt = "attempt"
+	StageResult  = "result"
+)
+
+// Results recorded on StageResult events.
+const (
+	ResultSuccess  = "success"
+	ResultRejected = "rejected"
+	ResultError    = "error"
+	// ResultPending records a write held for approval.
+	ResultPending = "pending"
+	// ResultDenied and ResultInvalid record writes refused before they were attempted:
+	// by authorization or rate limits, or because the request was malformed.
+	ResultDenied  = "denied"
+	ResultInvalid = "invalid"
+)
+
+// Event is a single audit record.
+type Event struct {
+	Time              time.Time `json:"time"`
+	Stage             string    `json:"stage"`
+	RequestID         string    `json:"requestId,omitempty"`
+	Caller            string    `json:"caller"`
+	SourceIP          string    `json:"sourceIp,omitempty"`
+	Namespace         string    `json:"namespace"`
+	Deployment        string    `json:"deployment"`
+	PreviousReplicas  *int32    `json:"previousReplicas,omitempty"`
+	RequestedReplicas int32     `json:"requestedReplicas"`
+	Reason            string    `json:"reason,omitempty"`
+	Ticket            string    `json:"ticket,omitempty"`
+	// Path is the request path of a write refused before it was attempted.
+	Path string `json:"path,omitempty"`
+	// FreezeWindow names the freeze window that refused the change, or that BreakGlass
+	// overrode.
+	FreezeWindow string `json:"freezeWindow,omitempty"`
+	BreakGlass   bool   `json:"breakGlass,omitempty"`
+	// Operation names the namespace operation (hibernate, wake) the change was part of.
+	Operation string `json:"operation,omitempty"`
+	// HPAOverride is set when the change pins the Deployment's HorizontalPodAutoscaler.
+	HPAOverride bool `json:"hpaOverride,omitempty"`
+	// ChangeID links records of a change held for approval; DecidedBy is the identity
+	// that approved or rejected it.
+	ChangeID  string `json:"changeId,omitempty"`
+	DecidedBy string `json:"decidedBy,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Sink persists audit events.
+type Sink interface {
+	Write(e Event) error
+	Close() error
+}
+
+// Logger fans events out to all sinks.
+type Logger struct {
+	sinks      []Sink
+	failClosed bool
+}
+
+// New returns a Logger writing to sinks. When failClosed is set, callers should refuse
+// the operation if the attempt record cannot be written.
+func New(failClosed bool, sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks, failClosed: failClosed}
+}
+
+// FailClosed reports whether audit failures must block the audited operation.
+func (l *Logger) FailClosed() bool {
+	return l != nil && l.failClosed
+}
+
+// Log writes e to every sink. Failures are logged and returned joined; a failing sink
+// does not prevent the others from receiving the event.
+func (l *Logger) Log(e Event) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.Write(e); err != nil {
+			slog.Error("write audit event", "deployment", e.Deployment, "stage", e.Stage, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every sink.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	var errs []error
+	for _, s := range l.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// WriterSink writes events as JSON lines to an io.Writer (e.g. os.Stdout).
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink writing JSON lines to w. Close does not close w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(e Event) error {
+	line, err := marshalLine(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+func (s *WriterSink) Close() error { return nil }
+
+func marshalLine(e Event) ([]byte, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit event: %w", err)
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// FileSink appends JSON lines to a file and rotates it by size. Rotated files are named
+// path.1 (newest) through path.N (oldest).
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens (or creates) path for appending. A maxBytes of 0 disables rotation.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+	s.f = f
+	s.size = fi.Size()
+	return nil
+}
+
+func (s *FileSink) Write(e Event) error {
+	line, err := marshalLine(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return fmt.Errorf("audit file %s is closed", s.path)
+	}
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			if s.f == nil {
+				return err
+			}
+			// Keep appending past the size limit rather than losing the record.
+			slog.Error("rotate audit file", "path", s.path, "error", err)
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write audit file: %w", err)
+	}
+	// Audit records must survive a crash right after the write.
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("sync audit file: %w", err)
+	}
+	return nil
+}
+
+// rotate shifts path.N-1 -> path.N, ..., path -> path.1 and reopens path. path is
+// reopened even when shifting fails, so a failed rotation leaves the sink writable.
+func (s *FileSink) rotate() error {
+	err := s.shift()
+	if openErr := s.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+func (s *FileSink) shift() error {
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return fmt.Errorf("close audit file: %w", err)
+	}
+
+	if s.maxBackups > 0 {
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			src := fmt.Sprintf("%s.%d", s.path, i)
+			if _, err := os.Stat(src); err == nil {
+				if err := os.Rename(src, fmt.Sprintf("%s.%d", s.path, i+1)); err != nil {
+					return fmt.Errorf("rotate audit file: %w", err)
+				}
+			}
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return fmt.Errorf("rotate audit file: %w", err)
+		}
+	} else if err := os.Truncate(s.path, 0); err != nil {
+		return fmt.Errorf("truncate audit file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var out []Event
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("decode %q: %v", sc.Text(), err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestFileSinkRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	line, err := marshalLine(Event{Deployment: "web", RequestedReplicas: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for two events per file.
+	s, err := NewFileSink(path, int64(2*len(line)), 2)
+	if err != nil {
+		t.Fatalf("new file sink: %v", err)
+	}
+	defer s.Close()
+
+	for i := int32(1); i <= 7; i++ {
+		if err := s.Write(Event{Deployment: "web", RequestedReplicas: i}); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	// 7 events: path.2 = {3,4}, path.1 = {5,6}, path = {7}; {1,2} were dropped.
+	for file, want := range map[string][]int32{
+		path:        {7},
+		path + ".1": {5, 6},
+		path + ".2": {3, 4},
+	} {
+		got := readEvents(t, file)
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d events, got %d", filepath.Base(file), len(want), len(got))
+		}
+		for i, e := range got {
+			if e.RequestedReplicas != want[i] {
+				t.Fatalf("%s: event %d has replicas %d, want %d", filepath.Base(file), i, e.RequestedReplicas, want[i])
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 2 backups, stat .3: %v", err)
+	}
+}
+
+func TestFileSinkKeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	line, err := marshalLine(Event{Deployment: "web", RequestedReplicas: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileSink(path, int64(len(line)), 1)
+	if err != nil {
+		t.Fatalf("new file sink: %v", err)
+	}
+	defer s.Close()
+
+	// A non-empty directory in the backup slot makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocker"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for i := int32(1); i <= 3; i++ {
+		if err := s.Write(Event{Deployment: "web", RequestedReplicas: i}); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	if got := readEvents(t, path); len(got) != 3 {
+		t.Fatalf("expected all 3 events in the unrotated file, got %d", len(got))
+	}
+
+	// Rotation resumes once the slot is free.
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(Event{Deployment: "web", RequestedReplicas: 4}); err != nil {
+		t.Fatalf("write 4: %v", err)
+	}
+	if got := readEvents(t, path); len(got) != 1 || got[0].RequestedReplicas != 4 {
+		t.Fatalf("expected only event 4 after rotation, got %+v", got)
+	}
+	if got := readEvents(t, path+".1"); len(got) != 3 {
+		t.Fatalf("expected 3 rotated events, got %d", len(got))
+	}
+}
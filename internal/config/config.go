@@ -3,8 +3,16 @@ package config
 import (
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/history"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/logging"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/tracing"
 )
 
 // Config holds runtime configuration for the service.
@@ -13,21 +21,169 @@ type Config struct {
 	ProbeListenAddr string
 	Namespace       string
 
+	// ReadyzPingTTL caches the Kubernetes connectivity check used by /readyz; 0 pings
+	// on every probe.
+	ReadyzPingTTL time.Duration
+
+	// AdminListenAddr enables the debug listener (pprof, cache and informer dumps,
+	// effective config). It is unauthenticated, so bind it to localhost. Empty disables it.
+	AdminListenAddr string
+
+	// LogFormat is "text" or "json"; LogLevel is debug, info, warn or error.
+	LogFormat string
+	LogLevel  string
+
+	// TracingExporter is "none", "otlp" or "stdout". The OTLP endpoint is configured via
+	// the standard OTEL_EXPORTER_OTLP_* variables.
+	TracingExporter    string
+	TracingSampleRatio float64
+
 	// TLS file paths (only required when TLSEnabled is true).
 	TLSCertFile     string
 	TLSKeyFile      string
 	TLSClientCAFile string
 
+	// TLSReadOnlyClientCAFile is an optional second client CA bundle. Clients whose
+	// certificate only chains to it may read but not modify deployments.
+	TLSReadOnlyClientCAFile string
+
+	// TLSClientCRLFiles lists PEM or DER encoded CRLs used to reject revoked client certificates.
+	TLSClientCRLFiles []string
+	// TLSCRLReloadInterval controls how often CRL files are checked for changes.
+	TLSCRLReloadInterval time.Duration
+
 	// TLSEnabled enables HTTPS with mutual TLS on the API listener.
 	TLSEnabled bool
+
+	// TLS policy. Versions are "1.2" or "1.3"; an empty max lets crypto/tls decide.
+	// Cipher suites use IANA names and only apply to TLS 1.2.
+	TLSMinVersion       string
+	TLSMaxVersion       string
+	TLSCipherSuites     []string
+	TLSCurvePreferences []string
+	// TLSClientAuth is ClientAuthRequire or ClientAuthVerifyIfGiven.
+	TLSClientAuth string
+
+	// Per-client rate limits (requests/second and burst) keyed by client identity,
+	// and a global cap on concurrent API requests. A rate or cap of 0 disables it.
+	RateLimitReadRPS    float64
+	RateLimitReadBurst  int
+	RateLimitWriteRPS   float64
+	RateLimitWriteBurst int
+	MaxInFlight         int
+
+	// ManagedOnly limits the service to Deployments that opt in via ManagedKey
+	// (annotation or label set to "true").
+	ManagedOnly bool
+	ManagedKey  string
+
+	// CacheStaleAfter reports not ready once the Deployment watch has failed and the
+	// cache has made no progress for this long. 0 disables the check.
+	CacheStaleAfter time.Duration
+
+	// ChangeCooldown, MaxStep and MaxStepPercent throttle changes per deployment; 0
+	// disables each. The kube package's annotations override them per deployment.
+	ChangeCooldown time.Duration
+	MaxStep        int
+	MaxStepPercent int
+
+	// ReplicaBudget caps the total replicas across cached deployments in Namespace;
+	// scale-ups beyond it are rejected. 0 disables the budget.
+	ReplicaBudget int
+
+	// QuotaCheck rejects scale-ups whose additional pods would exceed a ResourceQuota.
+	QuotaCheck bool
+
+	// HPACheck rejects direct scaling of deployments targeted by a
+	// HorizontalPodAutoscaler unless the request asks for an override, which pins the
+	// HPA's bounds for HPAOverrideTTL before they are restored.
+	HPACheck       bool
+	HPAOverrideTTL time.Duration
+
+	// PDBMode selects what happens to scale-downs that would leave a deployment's
+	// PodDisruptionBudget allowing no disruptions: PDBModeWarn, PDBModeReject, or
+	// PDBModeOff (also the empty string), which does not watch PDBs.
+	PDBMode string
+
+	// HistoryBackend persists replica history: history.BackendMemory, BackendFile
+	// (HistoryFile) or BackendConfigMap (HistoryConfigMap in Namespace).
+	HistoryBackend    string
+	HistoryFile       string
+	HistoryConfigMap  string
+	HistoryMaxEntries int
+
+	// AuditSinks lists where write operations are recorded (audit.SinkStdout, audit.SinkFile).
+	// The file sink appends JSON lines to AuditFile and rotates it at AuditFileMaxSizeMB.
+	AuditSinks          []string
+	AuditFile           string
+	AuditFileMaxSizeMB  int
+	AuditFileMaxBackups int
+	// AuditFailClosed rejects writes whose attempt cannot be recorded.
+	AuditFailClosed bool
+
+	// ScheduleFile holds scheduled scaling rules (YAML or JSON); empty disables the
+	// scheduler. Runs missed within ScheduleCatchUpWindow are applied on start.
+	ScheduleFile          string
+	ScheduleCatchUpWindow time.Duration
+
+	// FreezeFile holds change freeze windows (YAML or JSON); empty disables freezes.
+	FreezeFile string
+
+	// ApprovalScaleToZero and ApprovalMaxChangePercent select writes that are held until
+	// a second identity approves them; pending changes expire after ApprovalTTL.
+	ApprovalScaleToZero      bool
+	ApprovalMaxChangePercent int
+	ApprovalTTL              time.Duration
 }
 
+// PodDisruptionBudget modes accepted by PDB_MODE.
+const (
+	PDBModeOff    = "off"
+	PDBModeWarn   = "warn"
+	PDBModeReject = "reject"
+)
+
 // Load builds a Config from defaults, environment variables, and flags.
 func Load() (Config, error) {
 	cfg := Config{
 		ListenAddr:      ":8080",
 		ProbeListenAddr: ":8081",
 		Namespace:       "default",
+		ReadyzPingTTL:   10 * time.Second,
+		LogFormat:       logging.FormatText,
+		LogLevel:        "info",
+
+		TracingExporter:    tracing.ExporterNone,
+		TracingSampleRatio: 1,
+
+		TLSCRLReloadInterval: time.Minute,
+		TLSMinVersion:        "1.2",
+		TLSClientAuth:        ClientAuthRequire,
+
+		RateLimitReadRPS:    20,
+		RateLimitReadBurst:  40,
+		RateLimitWriteRPS:   1,
+		RateLimitWriteBurst: 5,
+		MaxInFlight:         64,
+
+		ManagedKey:      "replica-manager.io/managed",
+		CacheStaleAfter: 5 * time.Minute,
+
+		HPACheck:       true,
+		HPAOverrideTTL: time.Hour,
+		PDBMode:        PDBModeWarn,
+
+		HistoryBackend:    history.BackendMemory,
+		HistoryConfigMap:  "k8-replica-manager-history",
+		HistoryMaxEntries: history.DefaultMaxEntries,
+
+		AuditSinks:          []string{audit.SinkStdout},
+		AuditFileMaxSizeMB:  100,
+		AuditFileMaxBackups: 5,
+
+		ScheduleCatchUpWindow: time.Hour,
+
+		ApprovalTTL: time.Hour,
 	}
 
 	// env overrides
@@ -40,6 +196,24 @@ func Load() (Config, error) {
 	if v := os.Getenv("NAMESPACE"); v != "" {
 		cfg.Namespace = v
 	}
+	if err := envDuration("READYZ_PING_TTL", &cfg.ReadyzPingTTL); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("ADMIN_LISTEN_ADDR"); v != "" {
+		cfg.AdminListenAddr = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		cfg.LogFormat = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+	if v := os.Getenv("TRACING_EXPORTER"); v != "" {
+		cfg.TracingExporter = v
+	}
+	if err := envFloat("TRACING_SAMPLE_RATIO", &cfg.TracingSampleRatio); err != nil {
+		return Config{}, err
+	}
 	if v := os.Getenv("TLS_CERT_FILE"); v != "" {
 		cfg.TLSCertFile = v
 	}
@@ -49,6 +223,15 @@ func Load() (Config, error) {
 	if v := os.Getenv("TLS_CLIENT_CA_FILE"); v != "" {
 		cfg.TLSClientCAFile = v
 	}
+	if v := os.Getenv("TLS_READONLY_CLIENT_CA_FILE"); v != "" {
+		cfg.TLSReadOnlyClientCAFile = v
+	}
+	if v := os.Getenv("TLS_CLIENT_CRL_FILES"); v != "" {
+		cfg.TLSClientCRLFiles = splitList(v)
+	}
+	if err := envDuration("TLS_CRL_RELOAD_INTERVAL", &cfg.TLSCRLReloadInterval); err != nil {
+		return Config{}, err
+	}
 	if v := os.Getenv("TLS_ENABLED"); v != "" {
 		if b, err := strconv.ParseBool(v); err == nil {
 			cfg.TLSEnabled = b
@@ -57,21 +240,390 @@ func Load() (Config, error) {
 		}
 	}
 
+	if v := os.Getenv("TLS_MIN_VERSION"); v != "" {
+		cfg.TLSMinVersion = v
+	}
+	if v := os.Getenv("TLS_MAX_VERSION"); v != "" {
+		cfg.TLSMaxVersion = v
+	}
+	if v := os.Getenv("TLS_CIPHER_SUITES"); v != "" {
+		cfg.TLSCipherSuites = splitList(v)
+	}
+	if v := os.Getenv("TLS_CURVE_PREFERENCES"); v != "" {
+		cfg.TLSCurvePreferences = splitList(v)
+	}
+	if v := os.Getenv("TLS_CLIENT_AUTH"); v != "" {
+		cfg.TLSClientAuth = v
+	}
+
+	if err := envFloat("RATE_LIMIT_READ_RPS", &cfg.RateLimitReadRPS); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("RATE_LIMIT_READ_BURST", &cfg.RateLimitReadBurst); err != nil {
+		return Config{}, err
+	}
+	if err := envFloat("RATE_LIMIT_WRITE_RPS", &cfg.RateLimitWriteRPS); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("RATE_LIMIT_WRITE_BURST", &cfg.RateLimitWriteBurst); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MAX_IN_FLIGHT", &cfg.MaxInFlight); err != nil {
+		return Config{}, err
+	}
+
+	if err := envBool("MANAGED_ONLY", &cfg.ManagedOnly); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("MANAGED_KEY"); v != "" {
+		cfg.ManagedKey = v
+	}
+	if err := envDuration("CACHE_STALE_AFTER", &cfg.CacheStaleAfter); err != nil {
+		return Config{}, err
+	}
+
+	if err := envDuration("CHANGE_COOLDOWN", &cfg.ChangeCooldown); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MAX_STEP", &cfg.MaxStep); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MAX_STEP_PERCENT", &cfg.MaxStepPercent); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("REPLICA_BUDGET", &cfg.ReplicaBudget); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("QUOTA_CHECK", &cfg.QuotaCheck); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("HPA_CHECK", &cfg.HPACheck); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("HPA_OVERRIDE_TTL", &cfg.HPAOverrideTTL); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("PDB_MODE"); v != "" {
+		cfg.PDBMode = v
+	}
+
+	if v := os.Getenv("HISTORY_BACKEND"); v != "" {
+		cfg.HistoryBackend = v
+	}
+	if v := os.Getenv("HISTORY_FILE"); v != "" {
+		cfg.HistoryFile = v
+	}
+	if v := os.Getenv("HISTORY_CONFIGMAP"); v != "" {
+		cfg.HistoryConfigMap = v
+	}
+	if err := envInt("HISTORY_MAX_ENTRIES", &cfg.HistoryMaxEntries); err != nil {
+		return Config{}, err
+	}
+
+	if v := os.Getenv("SCHEDULE_FILE"); v != "" {
+		cfg.ScheduleFile = v
+	}
+	if err := envDuration("SCHEDULE_CATCH_UP_WINDOW", &cfg.ScheduleCatchUpWindow); err != nil {
+		return Config{}, err
+	}
+
+	if v := os.Getenv("FREEZE_FILE"); v != "" {
+		cfg.FreezeFile = v
+	}
+
+	if err := envBool("APPROVAL_SCALE_TO_ZERO", &cfg.ApprovalScaleToZero); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("APPROVAL_MAX_CHANGE_PERCENT", &cfg.ApprovalMaxChangePercent); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("APPROVAL_TTL", &cfg.ApprovalTTL); err != nil {
+		return Config{}, err
+	}
+
+	if v := os.Getenv("AUDIT_SINKS"); v != "" {
+		cfg.AuditSinks = splitList(v)
+	}
+	if v := os.Getenv("AUDIT_FILE"); v != "" {
+		cfg.AuditFile = v
+	}
+	if err := envInt("AUDIT_FILE_MAX_SIZE_MB", &cfg.AuditFileMaxSizeMB); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("AUDIT_FILE_MAX_BACKUPS", &cfg.AuditFileMaxBackups); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("AUDIT_FAIL_CLOSED", &cfg.AuditFailClosed); err != nil {
+		return Config{}, err
+	}
+
 	// flags override env
 	flag.StringVar(&cfg.ListenAddr, "listen-addr", cfg.ListenAddr, "address to listen on (env: LISTEN_ADDR)")
 	flag.StringVar(&cfg.ProbeListenAddr, "probe-listen-addr", cfg.ProbeListenAddr, "address for health probes (env: PROBE_LISTEN_ADDR)")
 	flag.StringVar(&cfg.Namespace, "namespace", cfg.Namespace, "kubernetes namespace to target (env: NAMESPACE)")
+	flag.DurationVar(&cfg.ReadyzPingTTL, "readyz-ping-ttl", cfg.ReadyzPingTTL, "cache /readyz kubernetes checks for this long, 0 disables (env: READYZ_PING_TTL)")
+	flag.StringVar(&cfg.AdminListenAddr, "admin-listen-addr", cfg.AdminListenAddr, "address for debug endpoints, empty disables (env: ADMIN_LISTEN_ADDR)")
+	flag.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log format: text or json (env: LOG_FORMAT)")
+	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "log level: debug, info, warn or error (env: LOG_LEVEL)")
+	flag.StringVar(&cfg.TracingExporter, "tracing-exporter", cfg.TracingExporter, "trace exporter: none, otlp or stdout (env: TRACING_EXPORTER)")
+	flag.Float64Var(&cfg.TracingSampleRatio, "tracing-sample-ratio", cfg.TracingSampleRatio, "fraction of new traces to sample (env: TRACING_SAMPLE_RATIO)")
 	flag.StringVar(&cfg.TLSCertFile, "tls-cert-file", cfg.TLSCertFile, "path to server TLS cert (env: TLS_CERT_FILE)")
 	flag.StringVar(&cfg.TLSKeyFile, "tls-key-file", cfg.TLSKeyFile, "path to server TLS key (env: TLS_KEY_FILE)")
 	flag.StringVar(&cfg.TLSClientCAFile, "tls-client-ca-file", cfg.TLSClientCAFile, "path to client CA bundle (env: TLS_CLIENT_CA_FILE)")
+	flag.StringVar(&cfg.TLSReadOnlyClientCAFile, "tls-readonly-client-ca-file", cfg.TLSReadOnlyClientCAFile, "path to read-only client CA bundle (env: TLS_READONLY_CLIENT_CA_FILE)")
+	flag.Var(listValue{&cfg.TLSClientCRLFiles}, "tls-client-crl-files", "comma-separated client CRL files (env: TLS_CLIENT_CRL_FILES)")
+	flag.DurationVar(&cfg.TLSCRLReloadInterval, "tls-crl-reload-interval", cfg.TLSCRLReloadInterval, "how often to check CRL files for changes (env: TLS_CRL_RELOAD_INTERVAL)")
 	flag.BoolVar(&cfg.TLSEnabled, "tls-enabled", cfg.TLSEnabled, "enable TLS listener (env: TLS_ENABLED)")
+	flag.StringVar(&cfg.TLSMinVersion, "tls-min-version", cfg.TLSMinVersion, "minimum TLS version: 1.2 or 1.3 (env: TLS_MIN_VERSION)")
+	flag.StringVar(&cfg.TLSMaxVersion, "tls-max-version", cfg.TLSMaxVersion, "maximum TLS version: 1.2 or 1.3 (env: TLS_MAX_VERSION)")
+	flag.Var(listValue{&cfg.TLSCipherSuites}, "tls-cipher-suites", "comma-separated TLS 1.2 cipher suites (env: TLS_CIPHER_SUITES)")
+	flag.Var(listValue{&cfg.TLSCurvePreferences}, "tls-curve-preferences", "comma-separated curve preferences (env: TLS_CURVE_PREFERENCES)")
+	flag.StringVar(&cfg.TLSClientAuth, "tls-client-auth", cfg.TLSClientAuth, "client auth mode: require or verify-if-given (env: TLS_CLIENT_AUTH)")
+	flag.Float64Var(&cfg.RateLimitReadRPS, "rate-limit-read-rps", cfg.RateLimitReadRPS, "per-client read requests/second, 0 disables (env: RATE_LIMIT_READ_RPS)")
+	flag.IntVar(&cfg.RateLimitReadBurst, "rate-limit-read-burst", cfg.RateLimitReadBurst, "per-client read burst (env: RATE_LIMIT_READ_BURST)")
+	flag.Float64Var(&cfg.RateLimitWriteRPS, "rate-limit-write-rps", cfg.RateLimitWriteRPS, "per-client write requests/second, 0 disables (env: RATE_LIMIT_WRITE_RPS)")
+	flag.IntVar(&cfg.RateLimitWriteBurst, "rate-limit-write-burst", cfg.RateLimitWriteBurst, "per-client write burst (env: RATE_LIMIT_WRITE_BURST)")
+	flag.IntVar(&cfg.MaxInFlight, "max-in-flight", cfg.MaxInFlight, "maximum concurrent API requests, 0 disables (env: MAX_IN_FLIGHT)")
+	flag.BoolVar(&cfg.ManagedOnly, "managed-only", cfg.ManagedOnly, "only expose deployments that opt in via the managed key (env: MANAGED_ONLY)")
+	flag.StringVar(&cfg.ManagedKey, "managed-key", cfg.ManagedKey, "annotation or label that opts a deployment in (env: MANAGED_KEY)")
+	flag.DurationVar(&cfg.CacheStaleAfter, "cache-stale-after", cfg.CacheStaleAfter, "report not ready when the watch is failing and the cache is older than this, 0 disables (env: CACHE_STALE_AFTER)")
+	flag.DurationVar(&cfg.ChangeCooldown, "change-cooldown", cfg.ChangeCooldown, "minimum time between changes to a deployment, 0 disables (env: CHANGE_COOLDOWN)")
+	flag.IntVar(&cfg.MaxStep, "max-step", cfg.MaxStep, "maximum replicas added or removed per change, 0 disables (env: MAX_STEP)")
+	flag.IntVar(&cfg.MaxStepPercent, "max-step-percent", cfg.MaxStepPercent, "maximum change as a percentage of current replicas, 0 disables (env: MAX_STEP_PERCENT)")
+	flag.IntVar(&cfg.ReplicaBudget, "replica-budget", cfg.ReplicaBudget, "maximum total replicas across deployments in the namespace, 0 disables (env: REPLICA_BUDGET)")
+	flag.BoolVar(&cfg.QuotaCheck, "quota-check", cfg.QuotaCheck, "reject scale-ups that would exceed a ResourceQuota (env: QUOTA_CHECK)")
+	flag.BoolVar(&cfg.HPACheck, "hpa-check", cfg.HPACheck, "reject direct scaling of HPA-managed deployments unless overridden (env: HPA_CHECK)")
+	flag.DurationVar(&cfg.HPAOverrideTTL, "hpa-override-ttl", cfg.HPAOverrideTTL, "how long an HPA override pins the autoscaler before its bounds are restored (env: HPA_OVERRIDE_TTL)")
+	flag.StringVar(&cfg.PDBMode, "pdb-mode", cfg.PDBMode, "scale-downs that would block PodDisruptionBudget evictions: off, warn or reject (env: PDB_MODE)")
+	flag.StringVar(&cfg.HistoryBackend, "history-backend", cfg.HistoryBackend, "replica history backend: memory, file or configmap (env: HISTORY_BACKEND)")
+	flag.StringVar(&cfg.HistoryFile, "history-file", cfg.HistoryFile, "path of the history file for the file backend (env: HISTORY_FILE)")
+	flag.StringVar(&cfg.HistoryConfigMap, "history-configmap", cfg.HistoryConfigMap, "name of the history ConfigMap for the configmap backend (env: HISTORY_CONFIGMAP)")
+	flag.IntVar(&cfg.HistoryMaxEntries, "history-max-entries", cfg.HistoryMaxEntries, "history entries kept per deployment, 0 uses the default (env: HISTORY_MAX_ENTRIES)")
+	flag.StringVar(&cfg.ScheduleFile, "schedule-file", cfg.ScheduleFile, "file with scheduled scaling rules, empty disables the scheduler (env: SCHEDULE_FILE)")
+	flag.DurationVar(&cfg.ScheduleCatchUpWindow, "schedule-catch-up-window", cfg.ScheduleCatchUpWindow, "apply scheduled runs missed within this window on start, 0 disables (env: SCHEDULE_CATCH_UP_WINDOW)")
+	flag.StringVar(&cfg.FreezeFile, "freeze-file", cfg.FreezeFile, "file with change freeze windows, empty disables freezes (env: FREEZE_FILE)")
+	flag.BoolVar(&cfg.ApprovalScaleToZero, "approval-scale-to-zero", cfg.ApprovalScaleToZero, "require a second approver for scaling to zero (env: APPROVAL_SCALE_TO_ZERO)")
+	flag.IntVar(&cfg.ApprovalMaxChangePercent, "approval-max-change-percent", cfg.ApprovalMaxChangePercent, "require a second approver for changes larger than this percentage, 0 disables (env: APPROVAL_MAX_CHANGE_PERCENT)")
+	flag.DurationVar(&cfg.ApprovalTTL, "approval-ttl", cfg.ApprovalTTL, "how long changes wait for approval before expiring (env: APPROVAL_TTL)")
+	flag.Var(listValue{&cfg.AuditSinks}, "audit-sinks", "comma-separated audit sinks: stdout, file (env: AUDIT_SINKS)")
+	flag.StringVar(&cfg.AuditFile, "audit-file", cfg.AuditFile, "path of the audit log for the file sink (env: AUDIT_FILE)")
+	flag.IntVar(&cfg.AuditFileMaxSizeMB, "audit-file-max-size-mb", cfg.AuditFileMaxSizeMB, "rotate the audit file at this size, 0 disables (env: AUDIT_FILE_MAX_SIZE_MB)")
+	flag.IntVar(&cfg.AuditFileMaxBackups, "audit-file-max-backups", cfg.AuditFileMaxBackups, "rotated audit files to keep (env: AUDIT_FILE_MAX_BACKUPS)")
+	flag.BoolVar(&cfg.AuditFailClosed, "audit-fail-closed", cfg.AuditFailClosed, "reject writes that cannot be audited (env: AUDIT_FAIL_CLOSED)")
 	flag.Parse()
 
-	if cfg.TLSEnabled {
-		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" || cfg.TLSClientCAFile == "" {
-			return Config{}, fmt.Errorf("tls enabled but TLS_CERT_FILE, TLS_KEY_FILE, or TLS_CLIENT_CA_FILE is missing")
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy of c that is safe to expose on debug endpoints. Config only
+// holds paths to secret material, but the private key location is masked regardless.
+func (c Config) Redacted() Config {
+	if c.TLSKeyFile != "" {
+		c.TLSKeyFile = redacted
+	}
+	return c
+}
+
+const redacted = "[redacted]"
+
+// Validate reports invalid or inconsistent settings.
+func (c Config) Validate() error {
+	if c.LogFormat != "" && c.LogFormat != logging.FormatText && c.LogFormat != logging.FormatJSON {
+		return fmt.Errorf("LOG_FORMAT must be %s or %s, got %q", logging.FormatText, logging.FormatJSON, c.LogFormat)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+
+	switch c.TracingExporter {
+	case "", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		return fmt.Errorf("TRACING_EXPORTER must be %s, %s or %s, got %q", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, c.TracingExporter)
+	}
+	if c.AdminListenAddr != "" && (c.AdminListenAddr == c.ListenAddr || c.AdminListenAddr == c.ProbeListenAddr) {
+		return fmt.Errorf("ADMIN_LISTEN_ADDR must differ from LISTEN_ADDR and PROBE_LISTEN_ADDR")
+	}
+	if c.CacheStaleAfter < 0 {
+		return fmt.Errorf("CACHE_STALE_AFTER must be >= 0")
+	}
+	if c.ReadyzPingTTL < 0 {
+		return fmt.Errorf("READYZ_PING_TTL must be >= 0")
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	if c.TLSEnabled {
+		if c.TLSCertFile == "" || c.TLSKeyFile == "" || c.TLSClientCAFile == "" {
+			return fmt.Errorf("tls enabled but TLS_CERT_FILE, TLS_KEY_FILE, or TLS_CLIENT_CA_FILE is missing")
 		}
+		if len(c.TLSClientCRLFiles) > 0 && c.TLSCRLReloadInterval <= 0 {
+			return fmt.Errorf("TLS_CRL_RELOAD_INTERVAL must be > 0 when CRL files are configured")
+		}
+		if c.TLSReadOnlyClientCAFile != "" && c.TLSReadOnlyClientCAFile == c.TLSClientCAFile {
+			return fmt.Errorf("TLS_READONLY_CLIENT_CA_FILE must differ from TLS_CLIENT_CA_FILE")
+		}
+		if err := c.validateTLS(); err != nil {
+			return err
+		}
+	} else if c.TLSReadOnlyClientCAFile != "" {
+		return fmt.Errorf("TLS_READONLY_CLIENT_CA_FILE requires TLS_ENABLED")
 	}
 
-	return cfg, nil
+	if c.RateLimitReadRPS > 0 && c.RateLimitReadBurst < 1 {
+		return fmt.Errorf("RATE_LIMIT_READ_BURST must be >= 1 when RATE_LIMIT_READ_RPS is set")
+	}
+	if c.RateLimitWriteRPS > 0 && c.RateLimitWriteBurst < 1 {
+		return fmt.Errorf("RATE_LIMIT_WRITE_BURST must be >= 1 when RATE_LIMIT_WRITE_RPS is set")
+	}
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("MAX_IN_FLIGHT must be >= 0")
+	}
+
+	if c.ChangeCooldown < 0 {
+		return fmt.Errorf("CHANGE_COOLDOWN must be >= 0")
+	}
+	if c.MaxStep < 0 || c.MaxStep > math.MaxInt32 {
+		return fmt.Errorf("MAX_STEP must be between 0 and %d", math.MaxInt32)
+	}
+	if c.MaxStepPercent < 0 || c.MaxStepPercent > math.MaxInt32 {
+		return fmt.Errorf("MAX_STEP_PERCENT must be between 0 and %d", math.MaxInt32)
+	}
+	if c.ReplicaBudget < 0 {
+		return fmt.Errorf("REPLICA_BUDGET must be >= 0")
+	}
+	if c.HPACheck && c.HPAOverrideTTL <= 0 {
+		return fmt.Errorf("HPA_OVERRIDE_TTL must be > 0 when HPA_CHECK is enabled")
+	}
+	switch c.PDBMode {
+	case "", PDBModeOff, PDBModeWarn, PDBModeReject:
+	default:
+		return fmt.Errorf("PDB_MODE must be %s, %s or %s, got %q", PDBModeOff, PDBModeWarn, PDBModeReject, c.PDBMode)
+	}
+
+	switch c.HistoryBackend {
+	case "", history.BackendMemory:
+	case history.BackendFile:
+		if c.HistoryFile == "" {
+			return fmt.Errorf("HISTORY_FILE is required for the %s history backend", history.BackendFile)
+		}
+	case history.BackendConfigMap:
+		if c.HistoryConfigMap == "" {
+			return fmt.Errorf("HISTORY_CONFIGMAP is required for the %s history backend", history.BackendConfigMap)
+		}
+	default:
+		return fmt.Errorf("HISTORY_BACKEND must be %s, %s or %s, got %q", history.BackendMemory, history.BackendFile, history.BackendConfigMap, c.HistoryBackend)
+	}
+	if c.HistoryMaxEntries < 0 {
+		return fmt.Errorf("HISTORY_MAX_ENTRIES must be >= 0")
+	}
+	if c.ScheduleCatchUpWindow < 0 {
+		return fmt.Errorf("SCHEDULE_CATCH_UP_WINDOW must be >= 0")
+	}
+	if c.ApprovalMaxChangePercent < 0 {
+		return fmt.Errorf("APPROVAL_MAX_CHANGE_PERCENT must be >= 0")
+	}
+	if (c.ApprovalScaleToZero || c.ApprovalMaxChangePercent > 0) && c.ApprovalTTL <= 0 {
+		return fmt.Errorf("APPROVAL_TTL must be > 0 when approvals are enabled")
+	}
+
+	for _, sink := range c.AuditSinks {
+		switch sink {
+		case audit.SinkStdout:
+		case audit.SinkFile:
+			if c.AuditFile == "" {
+				return fmt.Errorf("AUDIT_FILE is required for the %s audit sink", audit.SinkFile)
+			}
+		default:
+			return fmt.Errorf("AUDIT_SINKS: unknown sink %q (want %s or %s)", sink, audit.SinkStdout, audit.SinkFile)
+		}
+	}
+	if c.AuditFileMaxSizeMB < 0 || c.AuditFileMaxBackups < 0 {
+		return fmt.Errorf("AUDIT_FILE_MAX_SIZE_MB and AUDIT_FILE_MAX_BACKUPS must be >= 0")
+	}
+	if c.AuditFailClosed && len(c.AuditSinks) == 0 {
+		return fmt.Errorf("AUDIT_FAIL_CLOSED requires at least one audit sink")
+	}
+	return nil
+}
+
+func envBool(name string, dst *bool) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	*dst = b
+	return nil
+}
+
+func envInt(name string, dst *int) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envFloat(name string, dst *float64) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	*dst = f
+	return nil
+}
+
+func envDuration(name string, dst *time.Duration) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	*dst = d
+	return nil
+}
+
+// listValue is a flag.Value for comma-separated lists.
+type listValue struct{ p *[]string }
+
+func (l listValue) String() string {
+	if l.p == nil {
+		return ""
+	}
+	return strings.Join(*l.p, ",")
+}
+
+func (l listValue) Set(v string) error {
+	*l.p = splitList(v)
+	return nil
+}
+
+// splitList splits a comma-separated value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
 }
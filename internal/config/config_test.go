@@ -0,0 +1,118 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/history"
+)
+
+// validConfig returns the defaults Load starts from.
+func validConfig() Config {
+	return Config{
+		ListenAddr:           ":8080",
+		ProbeListenAddr:      ":8081",
+		Namespace:            "default",
+		ReadyzPingTTL:        10 * time.Second,
+		LogFormat:            "text",
+		LogLevel:             "info",
+		TracingExporter:      "none",
+		TracingSampleRatio:   1,
+		TLSCRLReloadInterval: time.Minute,
+		TLSMinVersion:        "1.2",
+		TLSClientAuth:        ClientAuthRequire,
+		RateLimitReadRPS:     20,
+		RateLimitReadBurst:   40,
+		RateLimitWriteRPS:    1,
+		RateLimitWriteBurst:  5,
+		MaxInFlight:          64,
+		ManagedKey:           "replica-manager.io/managed",
+		CacheStaleAfter:      5 * time.Minute,
+		HPACheck:             true,
+		HPAOverrideTTL:       time.Hour,
+		PDBMode:              PDBModeWarn,
+		HistoryBackend:       history.BackendMemory,
+		HistoryConfigMap:     "k8-replica-manager-history",
+		HistoryMaxEntries:    history.DefaultMaxEntries,
+		AuditSinks:           []string{audit.SinkStdout},
+		AuditFileMaxSizeMB:   100,
+		AuditFileMaxBackups:  5,
+		ApprovalTTL:          time.Hour,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string // empty means valid
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"empty optional enums", func(c *Config) { c.LogFormat, c.TracingExporter, c.PDBMode, c.HistoryBackend = "", "", "", "" }, ""},
+		{"log format", func(c *Config) { c.LogFormat = "xml" }, "LOG_FORMAT"},
+		{"log level", func(c *Config) { c.LogLevel = "verbose" }, "LOG_LEVEL"},
+		{"tracing exporter", func(c *Config) { c.TracingExporter = "zipkin" }, "TRACING_EXPORTER"},
+		{"tracing sample ratio", func(c *Config) { c.TracingSampleRatio = 1.5 }, "TRACING_SAMPLE_RATIO"},
+		{"admin listener", func(c *Config) { c.AdminListenAddr = "127.0.0.1:8082" }, ""},
+		{"admin listener on api address", func(c *Config) { c.AdminListenAddr = c.ListenAddr }, "ADMIN_LISTEN_ADDR"},
+		{"admin listener on probe address", func(c *Config) { c.AdminListenAddr = c.ProbeListenAddr }, "ADMIN_LISTEN_ADDR"},
+		{"negative cache stale after", func(c *Config) { c.CacheStaleAfter = -time.Second }, "CACHE_STALE_AFTER"},
+		{"negative readyz ping ttl", func(c *Config) { c.ReadyzPingTTL = -time.Second }, "READYZ_PING_TTL"},
+		{"read rate without burst", func(c *Config) { c.RateLimitReadBurst = 0 }, "RATE_LIMIT_READ_BURST"},
+		{"write rate without burst", func(c *Config) { c.RateLimitWriteBurst = 0 }, "RATE_LIMIT_WRITE_BURST"},
+		{"rate limits disabled", func(c *Config) {
+			c.RateLimitReadRPS, c.RateLimitReadBurst, c.RateLimitWriteRPS, c.RateLimitWriteBurst = 0, 0, 0, 0
+		}, ""},
+		{"negative max in flight", func(c *Config) { c.MaxInFlight = -1 }, "MAX_IN_FLIGHT"},
+		{"negative cooldown", func(c *Config) { c.ChangeCooldown = -time.Second }, "CHANGE_COOLDOWN"},
+		{"negative max step", func(c *Config) { c.MaxStep = -1 }, "MAX_STEP must"},
+		{"max step over int32", func(c *Config) { c.MaxStep = 1 << 31 }, "MAX_STEP must"},
+		{"negative max step percent", func(c *Config) { c.MaxStepPercent = -1 }, "MAX_STEP_PERCENT"},
+		{"negative replica budget", func(c *Config) { c.ReplicaBudget = -1 }, "REPLICA_BUDGET"},
+		{"hpa check without ttl", func(c *Config) { c.HPAOverrideTTL = 0 }, "HPA_OVERRIDE_TTL"},
+		{"hpa ttl unused without check", func(c *Config) { c.HPACheck, c.HPAOverrideTTL = false, 0 }, ""},
+		{"pdb reject", func(c *Config) { c.PDBMode = PDBModeReject }, ""},
+		{"pdb mode", func(c *Config) { c.PDBMode = "block" }, "PDB_MODE"},
+		{"file history", func(c *Config) { c.HistoryBackend, c.HistoryFile = history.BackendFile, "/data/history.json" }, ""},
+		{"file history without file", func(c *Config) { c.HistoryBackend = history.BackendFile }, "HISTORY_FILE"},
+		{"configmap history without name", func(c *Config) { c.HistoryBackend, c.HistoryConfigMap = history.BackendConfigMap, "" }, "HISTORY_CONFIGMAP"},
+		{"history backend", func(c *Config) { c.HistoryBackend = "redis" }, "HISTORY_BACKEND"},
+		{"negative history entries", func(c *Config) { c.HistoryMaxEntries = -1 }, "HISTORY_MAX_ENTRIES"},
+		{"negative catch-up window", func(c *Config) { c.ScheduleCatchUpWindow = -time.Minute }, "SCHEDULE_CATCH_UP_WINDOW"},
+		{"negative approval percent", func(c *Config) { c.ApprovalMaxChangePercent = -1 }, "APPROVAL_MAX_CHANGE_PERCENT"},
+		{"approvals without ttl", func(c *Config) { c.ApprovalScaleToZero, c.ApprovalTTL = true, 0 }, "APPROVAL_TTL"},
+		{"approval ttl unused without rules", func(c *Config) { c.ApprovalTTL = 0 }, ""},
+		{"file audit sink", func(c *Config) {
+			c.AuditSinks, c.AuditFile = []string{audit.SinkStdout, audit.SinkFile}, "/var/log/audit.log"
+		}, ""},
+		{"file audit sink without file", func(c *Config) { c.AuditSinks = []string{audit.SinkFile} }, "AUDIT_FILE is required"},
+		{"unknown audit sink", func(c *Config) { c.AuditSinks = []string{"syslog"} }, "AUDIT_SINKS"},
+		{"negative audit rotation", func(c *Config) { c.AuditFileMaxBackups = -1 }, "AUDIT_FILE_MAX_SIZE_MB"},
+		{"fail closed without sinks", func(c *Config) { c.AuditSinks, c.AuditFailClosed = nil, true }, "AUDIT_FAIL_CLOSED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.mutate(&c)
+			checkValidate(t, c, tt.wantErr)
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := tlsConfig()
+	if got := c.Redacted(); got.TLSKeyFile != redacted || got.TLSCertFile != c.TLSCertFile {
+		t.Fatalf("unexpected redaction: %+v", got)
+	}
+	if c.TLSKeyFile == redacted {
+		t.Fatal("Redacted modified its receiver")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" a, ,b ,,c")
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("splitList = %q", got)
+	}
+}
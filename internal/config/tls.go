@@ -0,0 +1,142 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// Client auth modes accepted by TLS_CLIENT_AUTH.
+const (
+	// ClientAuthRequire rejects connections without a valid client certificate.
+	ClientAuthRequire = "require"
+	// ClientAuthVerifyIfGiven verifies client certificates when presented but
+	// also accepts connections without one. Such clients are unauthenticated and
+	// only get read access.
+	ClientAuthVerifyIfGiven = "verify-if-given"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+}
+
+// ParseTLSVersion converts "1.2" or "1.3" to a crypto/tls version constant.
+// An empty string returns 0, which lets crypto/tls pick its default.
+func ParseTLSVersion(v string) (uint16, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if id, ok := tlsVersions[v]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q (want 1.2 or 1.3)", v)
+}
+
+// ParseCipherSuites converts IANA cipher suite names to IDs. Only TLS 1.2 suites
+// from Go's secure list are accepted; TLS 1.3 suites are not configurable.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	secure := make(map[string]*tls.CipherSuite)
+	for _, cs := range tls.CipherSuites() {
+		secure[cs.Name] = cs
+	}
+	insecure := make(map[string]bool)
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.Name] = true
+	}
+
+	out := make([]uint16, 0, len(names))
+	for _, name := range names {
+		cs, ok := secure[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("cipher suite %s is insecure and not allowed", name)
+		case !ok:
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		case !supportsTLS12(cs):
+			return nil, fmt.Errorf("cipher suite %s is a TLS 1.3 suite; TLS 1.3 suites are not configurable", name)
+		}
+		out = append(out, cs.ID)
+	}
+	return out, nil
+}
+
+// ParseCurvePreferences converts curve names (X25519, X25519MLKEM768, P256, P384, P521) to IDs.
+func ParseCurvePreferences(names []string) ([]tls.CurveID, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	out := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		id, ok := tlsCurves[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %s", name)
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// ParseClientAuth converts a TLS_CLIENT_AUTH mode to a crypto/tls client auth type.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unsupported client auth mode %q (want %s or %s)", mode, ClientAuthRequire, ClientAuthVerifyIfGiven)
+	}
+}
+
+func supportsTLS12(cs *tls.CipherSuite) bool {
+	for _, v := range cs.SupportedVersions {
+		if v == tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTLS checks that the TLS policy settings are individually valid and consistent.
+func (c Config) validateTLS() error {
+	minVersion, err := ParseTLSVersion(c.TLSMinVersion)
+	if err != nil {
+		return fmt.Errorf("TLS_MIN_VERSION: %w", err)
+	}
+	maxVersion, err := ParseTLSVersion(c.TLSMaxVersion)
+	if err != nil {
+		return fmt.Errorf("TLS_MAX_VERSION: %w", err)
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return fmt.Errorf("TLS_MIN_VERSION %s is greater than TLS_MAX_VERSION %s", c.TLSMinVersion, c.TLSMaxVersion)
+	}
+
+	if _, err := ParseCipherSuites(c.TLSCipherSuites); err != nil {
+		return fmt.Errorf("TLS_CIPHER_SUITES: %w", err)
+	}
+	if len(c.TLSCipherSuites) > 0 && minVersion == tls.VersionTLS13 {
+		return fmt.Errorf("TLS_CIPHER_SUITES has no effect when TLS_MIN_VERSION is 1.3")
+	}
+
+	if _, err := ParseCurvePreferences(c.TLSCurvePreferences); err != nil {
+		return fmt.Errorf("TLS_CURVE_PREFERENCES: %w", err)
+	}
+
+	if _, err := ParseClientAuth(c.TLSClientAuth); err != nil {
+		return fmt.Errorf("TLS_CLIENT_AUTH: %w", err)
+	}
+	return nil
+}
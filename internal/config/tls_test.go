@@ -0,0 +1,109 @@
+package config
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+// tlsConfig returns a valid TLS-enabled configuration.
+func tlsConfig() Config {
+	c := validConfig()
+	c.TLSEnabled = true
+	c.TLSCertFile = "/tls/tls.crt"
+	c.TLSKeyFile = "/tls/tls.key"
+	c.TLSClientCAFile = "/tls/ca.crt"
+	return c
+}
+
+func TestValidateTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string // empty means valid
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"tls 1.3 only", func(c *Config) { c.TLSMinVersion, c.TLSMaxVersion = "1.3", "1.3" }, ""},
+		{"no versions", func(c *Config) { c.TLSMinVersion, c.TLSMaxVersion = "", "" }, ""},
+		{"unknown min version", func(c *Config) { c.TLSMinVersion = "1.1" }, "TLS_MIN_VERSION: unsupported TLS version"},
+		{"unknown max version", func(c *Config) { c.TLSMaxVersion = "2" }, "TLS_MAX_VERSION: unsupported TLS version"},
+		{"min above max", func(c *Config) { c.TLSMinVersion, c.TLSMaxVersion = "1.3", "1.2" }, "greater than TLS_MAX_VERSION"},
+		{"secure tls 1.2 suites", func(c *Config) {
+			c.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}
+		}, ""},
+		{"suites with a tls 1.3 minimum", func(c *Config) {
+			c.TLSMinVersion = "1.3"
+			c.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+		}, "no effect when TLS_MIN_VERSION is 1.3"},
+		{"insecure suite", func(c *Config) { c.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} }, "is insecure"},
+		{"tls 1.3 suite", func(c *Config) { c.TLSCipherSuites = []string{"TLS_AES_128_GCM_SHA256"} }, "is a TLS 1.3 suite"},
+		{"unknown suite", func(c *Config) { c.TLSCipherSuites = []string{"TLS_MADE_UP"} }, "unknown cipher suite"},
+		{"curves", func(c *Config) { c.TLSCurvePreferences = []string{"x25519", "P256"} }, ""},
+		{"unknown curve", func(c *Config) { c.TLSCurvePreferences = []string{"P224"} }, "TLS_CURVE_PREFERENCES: unknown curve P224"},
+		{"verify if given", func(c *Config) { c.TLSClientAuth = ClientAuthVerifyIfGiven }, ""},
+		{"unknown client auth", func(c *Config) { c.TLSClientAuth = "optional" }, "TLS_CLIENT_AUTH: unsupported client auth mode"},
+		{"missing key", func(c *Config) { c.TLSKeyFile = "" }, "TLS_KEY_FILE"},
+		{"missing client ca", func(c *Config) { c.TLSClientCAFile = "" }, "TLS_CLIENT_CA_FILE"},
+		{"crl without reload interval", func(c *Config) {
+			c.TLSClientCRLFiles = []string{"/tls/ca.crl"}
+			c.TLSCRLReloadInterval = 0
+		}, "TLS_CRL_RELOAD_INTERVAL"},
+		{"read-only ca", func(c *Config) { c.TLSReadOnlyClientCAFile = "/tls/readonly-ca.crt" }, ""},
+		{"read-only ca same as client ca", func(c *Config) { c.TLSReadOnlyClientCAFile = c.TLSClientCAFile }, "must differ from TLS_CLIENT_CA_FILE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tlsConfig()
+			tt.mutate(&c)
+			checkValidate(t, c, tt.wantErr)
+		})
+	}
+}
+
+func TestTLSPolicyIgnoredWithoutTLS(t *testing.T) {
+	c := validConfig()
+	c.TLSMinVersion = "1.0"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected TLS policy to be ignored with TLS disabled, got %v", err)
+	}
+	c.TLSReadOnlyClientCAFile = "/tls/readonly-ca.crt"
+	checkValidate(t, c, "TLS_READONLY_CLIENT_CA_FILE requires TLS_ENABLED")
+}
+
+func TestParseTLSSettings(t *testing.T) {
+	if v, err := ParseTLSVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Fatalf("ParseTLSVersion(1.3) = %v, %v", v, err)
+	}
+	suites, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"})
+	if err != nil || len(suites) != 1 || suites[0] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Fatalf("ParseCipherSuites = %v, %v", suites, err)
+	}
+	curves, err := ParseCurvePreferences([]string{"x25519mlkem768", "P384"})
+	if err != nil || len(curves) != 2 || curves[0] != tls.X25519MLKEM768 || curves[1] != tls.CurveP384 {
+		t.Fatalf("ParseCurvePreferences = %v, %v", curves, err)
+	}
+	for mode, want := range map[string]tls.ClientAuthType{
+		"":                      tls.RequireAndVerifyClientCert,
+		ClientAuthRequire:       tls.RequireAndVerifyClientCert,
+		ClientAuthVerifyIfGiven: tls.VerifyClientCertIfGiven,
+	} {
+		if got, err := ParseClientAuth(mode); err != nil || got != want {
+			t.Errorf("ParseClientAuth(%q) = %v, %v, want %v", mode, got, err, want)
+		}
+	}
+}
+
+// checkValidate fails t unless c.Validate returns an error containing wantErr, or no
+// error when wantErr is empty.
+func checkValidate(t *testing.T, c Config, wantErr string) {
+	t.Helper()
+	err := c.Validate()
+	switch {
+	case wantErr == "" && err != nil:
+		t.Fatalf("expected valid config, got %v", err)
+	case wantErr != "" && err == nil:
+		t.Fatalf("expected error containing %q, got nil", wantErr)
+	case wantErr != "" && !strings.Contains(err.Error(), wantErr):
+		t.Fatalf("expected error containing %q, got %v", wantErr, err)
+	}
+}
@@ -0,0 +1,237 @@
+// Package freeze evaluates change freeze windows during which scale changes are refused.
+package freeze
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Window is a period during which scale changes are refused. It is either absolute
+// (Start and End) or recurring (Weekly), and applies to every deployment unless narrowed
+// by Namespaces or Selector.
+type Window struct {
+	Name  string     `json:"name"`
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+	// Weekly repeats the window every week.
+	Weekly *Weekly `json:"weekly,omitempty"`
+	// Namespaces limits the window to these namespaces; empty matches all.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector limits the window to deployments whose labels match.
+	Selector string `json:"selector,omitempty"`
+	// Allow lists identities that may still scale during the window.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// Weekly is a recurring window on the given weekdays. When End is not after Start the
+// window runs past midnight into the next day (equal times cover a full day).
+type Weekly struct {
+	// Days are weekday names or three-letter abbreviations (Mon, Tuesday, ...).
+	Days []string `json:"days"`
+	// Start and End are HH:MM in Timezone.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is an IANA zone name; empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// FrozenError reports that a change was refused by an active freeze window.
+type FrozenError struct {
+	Window string
+}
+
+func (e *FrozenError) Error() string {
+	return fmt.Sprintf("scale changes are frozen by window %q", e.Window)
+}
+
+// Calendar holds validated freeze windows.
+type Calendar struct {
+	windows []compiledWindow
+	// allow lists identities exempt from every window.
+	allow []string
+}
+
+// calendarFile is the on-disk format of FREEZE_FILE (YAML or JSON).
+type calendarFile struct {
+	Allow   []string `json:"allow,omitempty"`
+	Windows []Window `json:"windows"`
+}
+
+type compiledWindow struct {
+	Window
+	selector labels.Selector
+	days     [7]bool
+	start    time.Duration // offset from midnight
+	end      time.Duration
+	location *time.Location
+}
+
+// Load reads and validates freeze windows from a YAML or JSON file.
+func Load(path string) (*Calendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read freeze file: %w", err)
+	}
+	var f calendarFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("parse freeze file: %w", err)
+	}
+	return New(f.Windows, f.Allow...)
+}
+
+// New validates windows and returns a Calendar. Identities in allow are exempt from
+// every window.
+func New(windows []Window, allow ...string) (*Calendar, error) {
+	c := &Calendar{allow: allow}
+	seen := make(map[string]bool, len(windows))
+
+	for i, w := range windows {
+		if w.Name == "" {
+			return nil, fmt.Errorf("freeze window %d: name is required", i)
+		}
+		if seen[w.Name] {
+			return nil, fmt.Errorf("freeze window %q: duplicate name", w.Name)
+		}
+		seen[w.Name] = true
+
+		cw, err := compile(w)
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %q: %w", w.Name, err)
+		}
+		c.windows = append(c.windows, cw)
+	}
+	return c, nil
+}
+
+func compile(w Window) (compiledWindow, error) {
+	cw := compiledWindow{Window: w}
+
+	absolute := w.Start != nil || w.End != nil
+	switch {
+	case absolute && w.Weekly != nil:
+		return cw, fmt.Errorf("use either start/end or weekly, not both")
+	case absolute:
+		if w.Start == nil || w.End == nil {
+			return cw, fmt.Errorf("start and end are both required")
+		}
+		if !w.End.After(*w.Start) {
+			return cw, fmt.Errorf("end must be after start")
+		}
+	case w.Weekly != nil:
+		if len(w.Weekly.Days) == 0 {
+			return cw, fmt.Errorf("weekly.days is required")
+		}
+		for _, d := range w.Weekly.Days {
+			wd, ok := parseWeekday(d)
+			if !ok {
+				return cw, fmt.Errorf("weekly.days: unknown day %q", d)
+			}
+			cw.days[wd] = true
+		}
+		var err error
+		if cw.start, err = parseClock(w.Weekly.Start); err != nil {
+			return cw, fmt.Errorf("weekly.start: %w", err)
+		}
+		if cw.end, err = parseClock(w.Weekly.End); err != nil {
+			return cw, fmt.Errorf("weekly.end: %w", err)
+		}
+		cw.location = time.UTC
+		if w.Weekly.Timezone != "" {
+			if cw.location, err = time.LoadLocation(w.Weekly.Timezone); err != nil {
+				return cw, fmt.Errorf("weekly.timezone: %w", err)
+			}
+		}
+	default:
+		return cw, fmt.Errorf("start/end or weekly is required")
+	}
+
+	if w.Selector != "" {
+		sel, err := labels.Parse(w.Selector)
+		if err != nil {
+			return cw, fmt.Errorf("selector: %w", err)
+		}
+		cw.selector = sel
+	}
+	return cw, nil
+}
+
+// Windows returns the configured windows in file order.
+func (c *Calendar) Windows() []Window {
+	if c == nil {
+		return nil
+	}
+	out := make([]Window, 0, len(c.windows))
+	for _, w := range c.windows {
+		out = append(out, w.Window)
+	}
+	return out
+}
+
+// Check returns the first window that is active at now, applies to a deployment with the
+// given namespace and labels, and does not allow identity. It is nil-safe.
+func (c *Calendar) Check(namespace string, lbls map[string]string, identity string, now time.Time) (Window, bool) {
+	if c == nil || slices.Contains(c.allow, identity) {
+		return Window{}, false
+	}
+	for _, w := range c.windows {
+		if !w.activeAt(now) || !w.applies(namespace, lbls) || slices.Contains(w.Allow, identity) {
+			continue
+		}
+		return w.Window, true
+	}
+	return Window{}, false
+}
+
+func (w compiledWindow) applies(namespace string, lbls map[string]string) bool {
+	if len(w.Namespaces) > 0 && !slices.Contains(w.Namespaces, namespace) {
+		return false
+	}
+	return w.selector == nil || w.selector.Matches(labels.Set(lbls))
+}
+
+func (w compiledWindow) activeAt(now time.Time) bool {
+	if w.Weekly == nil {
+		return !now.Before(*w.Start) && now.Before(*w.End)
+	}
+
+	// Wall-clock offset, so windows keep their local times across DST changes.
+	t := now.In(w.location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	if w.start < w.end {
+		return w.days[today] && offset >= w.start && offset < w.end
+	}
+	// Runs past midnight: the tail of yesterday's window or the head of today's.
+	return (w.days[today] && offset >= w.start) || (w.days[yesterday] && offset < w.end)
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock parses HH:MM into an offset from midnight; 24:00 is accepted as an end.
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
@@ -0,0 +1,96 @@
+package freeze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadValidates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	c, err := Load(write("ok.yaml", `
+allow: [release-manager]
+windows:
+  - name: holidays
+    start: 2025-12-20T00:00:00Z
+    end: 2026-01-05T00:00:00Z
+  - name: friday-evening
+    weekly: {days: [Fri], start: "16:00", end: "08:00", timezone: Europe/Berlin}
+    selector: tier=frontend
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if ws := c.Windows(); len(ws) != 2 || ws[1].Weekly.Timezone != "Europe/Berlin" {
+		t.Fatalf("windows = %+v", ws)
+	}
+
+	for name, body := range map[string]string{
+		"noname.yaml":   "windows: [{start: 2025-01-01T00:00:00Z, end: 2025-01-02T00:00:00Z}]",
+		"both.yaml":     "windows: [{name: a, start: 2025-01-01T00:00:00Z, end: 2025-01-02T00:00:00Z, weekly: {days: [Mon], start: '00:00', end: '01:00'}}]",
+		"neither.yaml":  "windows: [{name: a}]",
+		"reversed.yaml": "windows: [{name: a, start: 2025-01-02T00:00:00Z, end: 2025-01-01T00:00:00Z}]",
+		"day.yaml":      "windows: [{name: a, weekly: {days: [Funday], start: '00:00', end: '01:00'}}]",
+		"clock.yaml":    "windows: [{name: a, weekly: {days: [Mon], start: '25:00', end: '01:00'}}]",
+		"tz.yaml":       "windows: [{name: a, weekly: {days: [Mon], start: '00:00', end: '01:00', timezone: Mars/Olympus}}]",
+		"dup.yaml":      "windows: [{name: a, weekly: {days: [Mon], start: '00:00', end: '01:00'}}, {name: a, weekly: {days: [Tue], start: '00:00', end: '01:00'}}]",
+	} {
+		if _, err := Load(write(name, body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	start := time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	c, err := New([]Window{
+		{Name: "holidays", Start: &start, End: &end, Namespaces: []string{"prod"}, Allow: []string{"oncall"}},
+		{Name: "friday-evening", Weekly: &Weekly{Days: []string{"fri"}, Start: "16:00", End: "08:00", Timezone: "Europe/Berlin"}, Selector: "tier=frontend"},
+	}, "release-manager")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	frontend := map[string]string{"tier": "frontend"}
+
+	cases := []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		identity  string
+		now       time.Time
+		want      string
+	}{
+		{"absolute active", "prod", nil, "alice", time.Date(2025, 12, 24, 12, 0, 0, 0, time.UTC), "holidays"},
+		{"absolute other namespace", "dev", nil, "alice", time.Date(2025, 12, 24, 12, 0, 0, 0, time.UTC), ""},
+		{"absolute end is exclusive", "prod", nil, "alice", end, ""},
+		{"window allow list", "prod", nil, "oncall", time.Date(2025, 12, 24, 12, 0, 0, 0, time.UTC), ""},
+		{"global allow list", "prod", nil, "release-manager", time.Date(2025, 12, 24, 12, 0, 0, 0, time.UTC), ""},
+		// 2025-03-07 is a Friday; 15:30 UTC is 16:30 in Berlin.
+		{"weekly active", "dev", frontend, "alice", time.Date(2025, 3, 7, 15, 30, 0, 0, time.UTC), "friday-evening"},
+		{"weekly before start", "dev", frontend, "alice", time.Date(2025, 3, 7, 14, 30, 0, 0, time.UTC), ""},
+		{"weekly past midnight", "dev", frontend, "alice", time.Date(2025, 3, 8, 6, 0, 0, 0, time.UTC), "friday-evening"},
+		{"weekly ended", "dev", frontend, "alice", time.Date(2025, 3, 8, 7, 0, 0, 0, time.UTC), ""},
+		{"weekly selector mismatch", "dev", map[string]string{"tier": "batch"}, "alice", time.Date(2025, 3, 7, 15, 30, 0, 0, time.UTC), ""},
+	}
+	for _, tc := range cases {
+		w, ok := c.Check(tc.namespace, tc.labels, tc.identity, tc.now)
+		if got := map[bool]string{true: w.Name}[ok]; got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	var nilCal *Calendar
+	if _, ok := nilCal.Check("prod", nil, "alice", time.Now()); ok {
+		t.Fatal("nil calendar must not freeze")
+	}
+}
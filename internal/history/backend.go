@@ -0,0 +1,163 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Backend names accepted by HISTORY_BACKEND.
+const (
+	BackendMemory    = "memory"
+	BackendFile      = "file"
+	BackendConfigMap = "configmap"
+)
+
+// FileBackend stores history as JSON in a local file.
+type FileBackend struct {
+	Path string
+}
+
+func (b FileBackend) Load() (map[string][]Entry, error) {
+	data, err := os.ReadFile(b.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// Save writes to a temporary file and renames it so a crash never leaves a torn file.
+func (b FileBackend) Save(entries map[string][]Entry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(b.Path), filepath.Base(b.Path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.Path)
+}
+
+// configMapKey is the ConfigMap data key holding the JSON history.
+const configMapKey = "history.json"
+
+// maxConfigMapData bounds the history stored by ConfigMapBackend, leaving headroom for
+// metadata under the API server's 1 MiB object limit.
+const maxConfigMapData = 900 << 10
+
+// ConfigMapBackend stores history in a ConfigMap, so it survives pod rescheduling
+// without a volume. ConfigMaps are limited to 1 MiB, so in namespaces with many
+// Deployments the oldest entries are dropped until the history fits (see fit).
+type ConfigMapBackend struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+func (b ConfigMapBackend) Load() (map[string][]Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cm, err := b.Client.CoreV1().ConfigMaps(b.Namespace).Get(ctx, b.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[configMapKey]
+	if !ok {
+		return nil, nil
+	}
+	return decode([]byte(data))
+}
+
+func (b ConfigMapBackend) Save(entries map[string][]Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := fit(entries, maxConfigMapData)
+	if err != nil {
+		return err
+	}
+
+	configMaps := b.Client.CoreV1().ConfigMaps(b.Namespace)
+	cm, err := configMaps.Get(ctx, b.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: b.Name, Namespace: b.Namespace},
+			Data:       map[string]string{configMapKey: string(data)},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[configMapKey] = string(data)
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// fit encodes entries in at most limit bytes, keeping fewer of the newest entries per
+// Deployment as needed.
+func fit(entries map[string][]Entry, limit int) ([]byte, error) {
+	data, err := json.Marshal(entries)
+	if err != nil || len(data) <= limit {
+		return data, err
+	}
+
+	keep := 0
+	for _, list := range entries {
+		keep = max(keep, len(list))
+	}
+	for len(data) > limit {
+		if keep <= 1 {
+			return nil, fmt.Errorf("history of %d deployments does not fit in %d bytes", len(entries), limit)
+		}
+		keep = keep * 3 / 4
+		trimmed := make(map[string][]Entry, len(entries))
+		for name, list := range entries {
+			trimmed[name] = trim(list, keep)
+		}
+		if data, err = json.Marshal(trimmed); err != nil {
+			return nil, err
+		}
+	}
+	slog.Warn("trimmed replica history to fit its size limit", "deployments", len(entries), "entries_per_deployment", keep, "limit_bytes", limit)
+	return data, nil
+}
+
+func decode(data []byte) (map[string][]Entry, error) {
+	var entries map[string][]Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("decode history: %w", err)
+	}
+	return entries, nil
+}
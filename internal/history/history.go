@@ -0,0 +1,186 @@
+// Package history keeps a bounded, persistent record of replica count changes per
+// Deployment, built from the informer's observations.
+package history
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+)
+
+// DefaultMaxEntries bounds the history kept per Deployment.
+const DefaultMaxEntries = 100
+
+// Entry is one observed spec.replicas transition.
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Replicas         int32     `json:"replicas"`
+	PreviousReplicas *int32    `json:"previousReplicas,omitempty"`
+	Generation       int64     `json:"generation"`
+	// Caller is set when the change was made through this service.
+	Caller string `json:"caller,omitempty"`
+	// LastScaledAt is the last-scaled-at annotation seen with this transition. A
+	// transition is only credited to the annotated caller when it advanced, since the
+	// annotations outlive the change they describe.
+	LastScaledAt *time.Time `json:"lastScaledAt,omitempty"`
+}
+
+// Backend persists the full history. Save replaces whatever was stored before.
+type Backend interface {
+	Load() (map[string][]Entry, error)
+	Save(map[string][]Entry) error
+}
+
+// Recorder records transitions in memory and persists them through a Backend.
+type Recorder struct {
+	backend    Backend
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string][]Entry
+	dirty   bool
+}
+
+// New loads existing history from backend (nil keeps history in memory only).
+func New(backend Backend, maxEntries int) (*Recorder, error) {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	r := &Recorder{
+		backend:    backend,
+		maxEntries: maxEntries,
+		entries:    make(map[string][]Entry),
+	}
+	if backend != nil {
+		entries, err := backend.Load()
+		if err != nil {
+			return nil, fmt.Errorf("load history: %w", err)
+		}
+		for name, list := range entries {
+			r.entries[name] = trim(list, maxEntries)
+		}
+	}
+	return r, nil
+}
+
+// Observe records o if its replica count differs from the last recorded entry. The first
+// observation of a Deployment is recorded, unattributed, as its starting point, and its
+// history is dropped when it leaves the cache. It is meant to be used as
+// kube.Options.Observer.
+func (r *Recorder) Observe(o kube.Observation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := r.entries[o.Name]
+	if o.Deleted {
+		if list != nil {
+			delete(r.entries, o.Name)
+			r.dirty = true
+		}
+		return
+	}
+	e := Entry{
+		Time:       o.ObservedAt,
+		Replicas:   o.Replicas,
+		Generation: o.Generation,
+	}
+	if !o.ScaledAt.IsZero() {
+		at := o.ScaledAt
+		e.LastScaledAt = &at
+	}
+
+	if n := len(list); n > 0 {
+		last := list[n-1]
+		if last.Replicas == o.Replicas {
+			return
+		}
+		prev := last.Replicas
+		e.PreviousReplicas = &prev
+
+		var prevScaledAt time.Time
+		if last.LastScaledAt != nil {
+			prevScaledAt = *last.LastScaledAt
+		}
+		if o.ScaledBy != "" && o.ScaledAt.After(prevScaledAt) {
+			e.Caller = o.ScaledBy
+		}
+	}
+
+	r.entries[o.Name] = trim(append(list, e), r.maxEntries)
+	r.dirty = true
+}
+
+// List returns the entries for name within [since, until]. Zero bounds are open.
+func (r *Recorder) List(name string, since, until time.Time) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := []Entry{}
+	for _, e := range r.entries[name] {
+		if !since.IsZero() && e.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && e.Time.After(until) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Flush persists the history if it changed since the last flush.
+func (r *Recorder) Flush() error {
+	if r.backend == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return nil
+	}
+	snapshot := make(map[string][]Entry, len(r.entries))
+	for name, list := range r.entries {
+		snapshot[name] = append([]Entry(nil), list...)
+	}
+	r.dirty = false
+	r.mu.Unlock()
+
+	if err := r.backend.Save(snapshot); err != nil {
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+		return fmt.Errorf("save history: %w", err)
+	}
+	return nil
+}
+
+// Run flushes periodically until stop is closed, then flushes once more.
+func (r *Recorder) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			if err := r.Flush(); err != nil {
+				slog.Error("flush replica history", "error", err)
+			}
+			return
+		case <-t.C:
+			if err := r.Flush(); err != nil {
+				slog.Error("flush replica history", "error", err)
+			}
+		}
+	}
+}
+
+func trim(list []Entry, max int) []Entry {
+	if len(list) <= max {
+		return list
+	}
+	return append([]Entry(nil), list[len(list)-max:]...)
+}
@@ -0,0 +1,124 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var t0 = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func observe(r *Recorder, replicas int32, at time.Time, scaledBy string, scaledAt time.Time) {
+	r.Observe(kube.Observation{Name: "web", Replicas: replicas, ObservedAt: at, ScaledBy: scaledBy, ScaledAt: scaledAt})
+}
+
+func TestRecorderRecordsTransitions(t *testing.T) {
+	r, err := New(nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observe(r, 2, t0, "", time.Time{})
+	observe(r, 2, t0.Add(time.Minute), "", time.Time{}) // resync, no change
+	observe(r, 5, t0.Add(2*time.Minute), "alice", t0.Add(2*time.Minute))
+	// Stale attribution from alice's change must not be credited for a kubectl edit.
+	observe(r, 4, t0.Add(3*time.Minute), "alice", t0.Add(2*time.Minute))
+
+	got := r.List("web", time.Time{}, time.Time{})
+	if len(got) != 3 {
+		t.Fatalf("expected 3 transitions, got %+v", got)
+	}
+	if got[1].Caller != "alice" || *got[1].PreviousReplicas != 2 {
+		t.Fatalf("expected alice's change from 2, got %+v", got[1])
+	}
+	if got[2].Caller != "" || *got[2].PreviousReplicas != 5 {
+		t.Fatalf("expected unattributed change from 5, got %+v", got[2])
+	}
+
+	observe(r, 1, t0.Add(4*time.Minute), "", time.Time{})
+	if got := r.List("web", time.Time{}, time.Time{}); len(got) != 3 || got[0].Replicas != 5 {
+		t.Fatalf("expected oldest entry to be dropped, got %+v", got)
+	}
+
+	if got := r.List("web", t0.Add(3*time.Minute), t0.Add(3*time.Minute)); len(got) != 1 || got[0].Replicas != 4 {
+		t.Fatalf("expected time-range filter to match one entry, got %+v", got)
+	}
+}
+
+func TestBackendsRoundTrip(t *testing.T) {
+	backends := map[string]Backend{
+		"file":      FileBackend{Path: filepath.Join(t.TempDir(), "history.json")},
+		"configmap": ConfigMapBackend{Client: fake.NewClientset(), Namespace: "test", Name: "history"},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			r, err := New(backend, 10)
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+			observe(r, 2, t0, "", time.Time{})
+			observe(r, 3, t0.Add(time.Minute), "bob", t0.Add(time.Minute))
+			if err := r.Flush(); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			// A second flush updates the existing object/file.
+			observe(r, 4, t0.Add(2*time.Minute), "", time.Time{})
+			if err := r.Flush(); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+
+			restored, err := New(backend, 10)
+			if err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			got := restored.List("web", time.Time{}, time.Time{})
+			if len(got) != 3 || got[1].Caller != "bob" || !got[2].Time.Equal(t0.Add(2*time.Minute)) {
+				t.Fatalf("unexpected restored history: %+v", got)
+			}
+		})
+	}
+}
+
+func TestRecorderDropsDeletedDeployments(t *testing.T) {
+	r, err := New(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	observe(r, 2, t0, "", time.Time{})
+	r.Observe(kube.Observation{Name: "web", ObservedAt: t0.Add(time.Minute), Deleted: true})
+	if got := r.List("web", time.Time{}, time.Time{}); len(got) != 0 {
+		t.Fatalf("expected history to be dropped, got %+v", got)
+	}
+}
+
+func TestFitDropsOldestEntries(t *testing.T) {
+	entries := map[string][]Entry{}
+	for _, name := range []string{"api", "web", "worker"} {
+		for i := range 50 {
+			entries[name] = append(entries[name], Entry{Time: t0.Add(time.Duration(i) * time.Minute), Replicas: int32(i)})
+		}
+	}
+
+	data, err := fit(entries, 2000)
+	if err != nil {
+		t.Fatalf("fit: %v", err)
+	}
+	if len(data) > 2000 {
+		t.Fatalf("expected at most 2000 bytes, got %d", len(data))
+	}
+	got, err := decode(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	web := got["web"]
+	if len(web) == 0 || len(web) == 50 || web[len(web)-1].Replicas != 49 {
+		t.Fatalf("expected the newest entries to be kept, got %+v", web)
+	}
+
+	if _, err := fit(entries, 10); err == nil {
+		t.Fatalf("expected an error when even one entry per deployment does not fit")
+	}
+}
@@ -0,0 +1,83 @@
+package kube
+
+import "fmt"
+
+// BudgetError reports a scale-up that would push the namespace over its replica budget.
+type BudgetError struct {
+	Budget    int64
+	Used      int64
+	Requested int64 // total after the change
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("change would raise the namespace total to %d replicas, over the budget of %d (%d in use)", e.Requested, e.Budget, e.Used)
+}
+
+// pendingScale is a change patched by this process that the informer has not delivered
+// yet. It is identified by the resourceVersion the patch returned; base is the cached
+// resourceVersion when it was recorded.
+type pendingScale struct {
+	replicas        int32
+	resourceVersion string
+	base            string
+}
+
+// lockBudget serializes scale-ups while Options.ReplicaBudget is set, from the budget
+// check until the change is recorded as pending, and returns the unlock function. It is
+// taken after lockDeployment.
+func (m *Manager) lockBudget() func() {
+	if m.opts.ReplicaBudget <= 0 {
+		return func() {}
+	}
+	m.budgetMu.Lock()
+	return m.budgetMu.Unlock
+}
+
+// checkBudget returns a *BudgetError when scaling the named Deployment to replicas would
+// take the namespace total over Options.ReplicaBudget. Scale-downs are always allowed,
+// so a namespace that is already over budget can recover. Hold lockBudget.
+func (m *Manager) checkBudget(name string, replicas int32) error {
+	if m.opts.ReplicaBudget <= 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	current := m.deployments[name].Replicas
+	if p, ok := m.pending[name]; ok {
+		current = p.replicas
+	}
+	m.mu.Unlock()
+	if replicas <= current {
+		return nil
+	}
+
+	used, _ := m.TotalReplicas()
+	total := used - int64(current) + int64(replicas)
+	if total > m.opts.ReplicaBudget {
+		return &BudgetError{Budget: m.opts.ReplicaBudget, Used: used, Requested: total}
+	}
+	return nil
+}
+
+// notePending records a patched change until the informer delivers it, so replica totals
+// count it at once. Nothing is recorded if the informer has already delivered it.
+func (m *Manager) notePending(name string, replicas int32, resourceVersion string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	base := m.deployments[name].ResourceVersion
+	if resourceVersion != "" && base == resourceVersion {
+		return
+	}
+	m.pending[name] = pendingScale{replicas: replicas, resourceVersion: resourceVersion, base: base}
+}
+
+// observePending drops the pending change for d once the informer delivers it, or any
+// version after the one cached when it was recorded, since a later change supersedes
+// the patch. The patched replicas also match should the resourceVersion be unknown.
+// Hold m.mu.
+func (m *Manager) observePending(d Deployment) {
+	p, ok := m.pending[d.Name]
+	if ok && (d.ResourceVersion != p.base || p.resourceVersion == d.ResourceVersion || p.replicas == d.Replicas) {
+		delete(m.pending, d.Name)
+	}
+}
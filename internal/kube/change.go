@@ -0,0 +1,27 @@
+package kube
+
+import "context"
+
+// Change describes who requested a write and why. It travels in the request context so
+// the Store interface does not grow a parameter for every new piece of attribution.
+type Change struct {
+	// Caller identifies the client (certificate CN or remote IP).
+	Caller string
+	// Reason is an optional free-text justification supplied by the caller.
+	Reason string
+	// Ticket optionally links the change to a change-management ticket.
+	Ticket string
+}
+
+type changeKey struct{}
+
+// WithChange returns a copy of ctx carrying c.
+func WithChange(ctx context.Context, c Change) context.Context {
+	return context.WithValue(ctx, changeKey{}, c)
+}
+
+// ChangeFromContext returns the Change stored in ctx, or the zero value.
+func ChangeFromContext(ctx context.Context) Change {
+	c, _ := ctx.Value(changeKey{}).(Change)
+	return c
+}
@@ -0,0 +1,71 @@
+package kube
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Event reasons recorded on Deployments scaled through the service.
+const (
+	EventReasonScaled        = "Scaled"
+	EventReasonScaleRejected = "ScaleRejected"
+	EventReasonScaleFailed   = "ScaleFailed"
+
+	// EventReasonHPAOverrideInvalid is recorded on a HorizontalPodAutoscaler whose
+	// override annotations could not be parsed when the override was cleared.
+	EventReasonHPAOverrideInvalid = "HPAOverrideInvalid"
+
+	eventAction = "Scale"
+	// hpaEventAction is the action of events recorded on HorizontalPodAutoscalers.
+	hpaEventAction = "RestoreOverride"
+	// eventsComponent is the reportingController of emitted events.
+	eventsComponent = "k8-replica-manager"
+	// maxEventNote is the events.k8s.io/v1 limit on Event.note.
+	maxEventNote = 1024
+)
+
+// recordScaleEvent records an Event on the named Deployment. Events are best effort: a
+// Deployment missing from the informer store (e.g. just created) is skipped.
+func (m *Manager) recordScaleEvent(name, eventType, reason string, change Change, from *int32, to int32, cause error) {
+	d, err := m.lister.Deployments(m.namespace).Get(name)
+	if err != nil {
+		slog.Debug("skipping scale event for uncached deployment", "namespace", m.namespace, "deployment", name, "error", err)
+		return
+	}
+
+	m.recorder.Eventf(d, nil, eventType, reason, eventAction, "%s", scaleEventNote(change, from, to, cause))
+}
+
+// scaleEventNote formats the human-readable part of a scale event, e.g.
+// "scaled from 2 to 5 by alice: traffic spike".
+func scaleEventNote(change Change, from *int32, to int32, cause error) string {
+	verb := "scaled"
+	if cause != nil {
+		verb = "failed to scale"
+	}
+
+	note := fmt.Sprintf("%s to %d", verb, to)
+	if from != nil {
+		note = fmt.Sprintf("%s from %d to %d", verb, *from, to)
+	}
+
+	caller := change.Caller
+	if caller == "" {
+		caller = "unknown caller"
+	}
+	note += " by " + caller
+	if change.Reason != "" {
+		note += ": " + change.Reason
+	}
+	if change.Ticket != "" {
+		note += " [" + change.Ticket + "]"
+	}
+	if cause != nil {
+		note += " (" + cause.Error() + ")"
+	}
+
+	if len(note) > maxEventNote {
+		note = note[:maxEventNote]
+	}
+	return note
+}
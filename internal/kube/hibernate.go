@@ -0,0 +1,125 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HibernatedReplicasAnnotation records the replicas a Deployment had when it was
+	// hibernated; Wake restores them and removes it.
+	HibernatedReplicasAnnotation = "replica-manager.io/hibernated-replicas"
+	// HibernateExcludeLabel set to "true" keeps a Deployment running through namespace
+	// hibernation.
+	HibernateExcludeLabel = "replica-manager.io/hibernate-exclude"
+)
+
+// ErrNotHibernated is returned by Wake for a Deployment without a recorded count.
+var ErrNotHibernated = errors.New("deployment is not hibernated")
+
+// Hibernator is optional. It scales Deployments to zero and back to their recorded
+// count, subject to the same bounds, change limits, HPA, quota and budget checks as
+// SetReplicas. HPA-managed Deployments cannot be hibernated, since an HPA cannot be
+// pinned to 0.
+type Hibernator interface {
+	// Hibernate records the Deployment's replicas in HibernatedReplicasAnnotation and
+	// scales it to 0. It reports false, without changing anything, when the Deployment
+	// is already hibernated.
+	Hibernate(ctx context.Context, name string) (bool, error)
+	// Wake restores the recorded replicas, returning them, or ErrNotHibernated.
+	Wake(ctx context.Context, name string) (int32, error)
+}
+
+// Excluded reports whether d opted out of namespace hibernation via
+// HibernateExcludeLabel.
+func (d Deployment) Excluded() bool {
+	return d.Labels[HibernateExcludeLabel] == "true"
+}
+
+// Hibernate scales the named Deployment to 0, recording its replicas first.
+func (m *Manager) Hibernate(ctx context.Context, name string) (changed bool, err error) {
+	ctx, span := m.startSpan(ctx, "Hibernate", attribute.String("k8s.deployment.name", name))
+	defer func() { endSpan(span, err) }()
+
+	d, live, unlock, err := m.getLive(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if parseReplicaAnnotation(live, HibernatedReplicasAnnotation) != nil {
+		return false, nil
+	}
+
+	defer m.lockBudget()()
+	change := ChangeFromContext(ctx)
+	replicas := d.Replicas
+	now := time.Now()
+	if _, err := m.checkScale(d, 0, false, now); err != nil {
+		m.recordScaleEvent(name, corev1.EventTypeWarning, EventReasonScaleRejected, change, &replicas, 0, err)
+		return false, err
+	}
+	if err := m.applyScale(ctx, name, 0, &replicas, change, now, &replicas, live.ResourceVersion); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Wake scales the named Deployment back to the replicas recorded by Hibernate.
+func (m *Manager) Wake(ctx context.Context, name string) (replicas int32, err error) {
+	ctx, span := m.startSpan(ctx, "Wake", attribute.String("k8s.deployment.name", name))
+	defer func() { endSpan(span, err) }()
+
+	d, live, unlock, err := m.getLive(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	recorded := parseReplicaAnnotation(live, HibernatedReplicasAnnotation)
+	if recorded == nil {
+		return 0, ErrNotHibernated
+	}
+
+	defer m.lockBudget()()
+	change := ChangeFromContext(ctx)
+	prev := d.Replicas
+	now := time.Now()
+	if _, err := m.checkScale(d, *recorded, false, now); err != nil {
+		m.recordScaleEvent(name, corev1.EventTypeWarning, EventReasonScaleRejected, change, &prev, *recorded, err)
+		return 0, err
+	}
+	if err := m.applyScale(ctx, name, *recorded, &prev, change, now, nil, live.ResourceVersion); err != nil {
+		return 0, err
+	}
+	return *recorded, nil
+}
+
+// getLive locks changes to a cached Deployment (see snapshot) and reads it from the API
+// server. Hibernate and Wake decide from the live annotation so that a retry
+// arriving before the informer catches up cannot record 0 as the count to restore, and
+// patch with the live resourceVersion so that a change in between fails with a conflict
+// instead of being overwritten. d is the cached view for the policy checks, with the
+// live replicas. The caller must call unlock when err is nil.
+func (m *Manager) getLive(ctx context.Context, name string) (d Deployment, live *appsv1.Deployment, unlock func(), err error) {
+	d, unlock, found := m.snapshot(ctx, name)
+	if !found {
+		return Deployment{}, nil, nil, apierrors.NewNotFound(appsv1.Resource("deployments"), name)
+	}
+	live, err = m.client.AppsV1().Deployments(m.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		unlock()
+		return Deployment{}, nil, nil, fmt.Errorf("get deployment: %w", err)
+	}
+	d.Replicas = 0
+	if live.Spec.Replicas != nil {
+		d.Replicas = *live.Spec.Replicas
+	}
+	return d, live, unlock, nil
+}
@@ -0,0 +1,306 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Annotations recording an HPA override on the HorizontalPodAutoscaler itself, so the
+// original bounds are restored even if the service restarts during the override.
+const (
+	HPAOriginalMinAnnotation   = "replica-manager.io/hpa-original-min-replicas"
+	HPAOriginalMaxAnnotation   = "replica-manager.io/hpa-original-max-replicas"
+	HPAOverrideUntilAnnotation = "replica-manager.io/hpa-override-until"
+)
+
+// DefaultHPAOverrideTTL is how long an HPA override lasts when Options.HPAOverrideTTL
+// is unset.
+const DefaultHPAOverrideTTL = time.Hour
+
+// hpaRestoreInterval is how often expired HPA overrides are looked for.
+const hpaRestoreInterval = 15 * time.Second
+
+// HPA summarizes the HorizontalPodAutoscaler targeting a Deployment.
+type HPA struct {
+	Name            string
+	MinReplicas     int32
+	MaxReplicas     int32
+	CurrentReplicas int32
+	DesiredReplicas int32
+
+	// Override is set while SetReplicas has pinned the HPA (see WithHPAOverride).
+	Override *HPAOverride
+}
+
+// HPAOverride describes a temporary pin of an HPA's bounds.
+type HPAOverride struct {
+	Until time.Time
+	// Original bounds restored when the override ends; OriginalMinReplicas is nil when
+	// minReplicas was unset.
+	OriginalMinReplicas *int32
+	OriginalMaxReplicas int32
+}
+
+// HPAConflictError reports a direct scale of a Deployment managed by an HPA, which the
+// autoscaler would quickly undo.
+type HPAConflictError struct {
+	Name string
+	HPA  HPA
+	// Override is set when an override was requested but cannot pin replicas, which
+	// HPAs require to be at least 1.
+	Override bool
+}
+
+func (e *HPAConflictError) Error() string {
+	if e.Override {
+		return fmt.Sprintf("cannot pin HorizontalPodAutoscaler %q of deployment %q to 0 replicas", e.HPA.Name, e.Name)
+	}
+	return fmt.Sprintf("deployment %q is managed by HorizontalPodAutoscaler %q (min %d, max %d); request an HPA override to scale it directly",
+		e.Name, e.HPA.Name, e.HPA.MinReplicas, e.HPA.MaxReplicas)
+}
+
+// checkHPA returns a *HPAConflictError when d is managed by an HPA and the change cannot
+// be applied: no override was requested, or the override would pin the HPA to 0.
+func checkHPA(d Deployment, replicas int32, override bool) error {
+	if d.HPA == nil {
+		return nil
+	}
+	if !override {
+		return &HPAConflictError{Name: d.Name, HPA: *d.HPA}
+	}
+	if replicas < 1 {
+		return &HPAConflictError{Name: d.Name, HPA: *d.HPA, Override: true}
+	}
+	return nil
+}
+
+type hpaOverrideKey struct{}
+
+// WithHPAOverride returns a copy of ctx that lets SetReplicas scale an HPA-managed
+// Deployment by temporarily pinning the HPA's minReplicas and maxReplicas to the
+// requested count.
+func WithHPAOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hpaOverrideKey{}, true)
+}
+
+// HPAOverrideFromContext reports whether ctx carries WithHPAOverride.
+func HPAOverrideFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(hpaOverrideKey{}).(bool)
+	return v
+}
+
+// hpaTargets maps Deployment names to the HPA scaling them. Should several HPAs target
+// one Deployment, the first by name wins.
+func (m *Manager) hpaTargets() map[string]*autoscalingv2.HorizontalPodAutoscaler {
+	if m.hpaLister == nil {
+		return nil
+	}
+	hpas, err := m.hpaLister.HorizontalPodAutoscalers(m.namespace).List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+	sort.Slice(hpas, func(i, j int) bool { return hpas[i].Name < hpas[j].Name })
+	out := make(map[string]*autoscalingv2.HorizontalPodAutoscaler, len(hpas))
+	for _, h := range hpas {
+		ref := h.Spec.ScaleTargetRef
+		if ref.Kind != "Deployment" {
+			continue
+		}
+		if _, ok := out[ref.Name]; !ok {
+			out[ref.Name] = h
+		}
+	}
+	return out
+}
+
+// newHPA summarizes h. MinReplicas defaults to 1, as the API server does.
+func newHPA(h *autoscalingv2.HorizontalPodAutoscaler) *HPA {
+	out := &HPA{
+		Name:            h.Name,
+		MinReplicas:     1,
+		MaxReplicas:     h.Spec.MaxReplicas,
+		CurrentReplicas: h.Status.CurrentReplicas,
+		DesiredReplicas: h.Status.DesiredReplicas,
+	}
+	if h.Spec.MinReplicas != nil {
+		out.MinReplicas = *h.Spec.MinReplicas
+	}
+	if until, ok := overrideUntil(h); ok {
+		o := &HPAOverride{Until: until}
+		o.OriginalMinReplicas, o.OriginalMaxReplicas, _ = originalBounds(h)
+		out.Override = o
+	}
+	return out
+}
+
+// overrideUntil returns when the override on h expires, if h is overridden.
+func overrideUntil(h *autoscalingv2.HorizontalPodAutoscaler) (time.Time, bool) {
+	v, ok := h.Annotations[HPAOverrideUntilAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		// Restore malformed overrides at once rather than pinning the HPA forever.
+		return time.Time{}, true
+	}
+	return t, true
+}
+
+// originalBounds parses the bounds recorded before h was overridden.
+func originalBounds(h *autoscalingv2.HorizontalPodAutoscaler) (*int32, int32, error) {
+	var minReplicas *int32
+	if v := h.Annotations[HPAOriginalMinAnnotation]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid %s annotation %q", HPAOriginalMinAnnotation, v)
+		}
+		n32 := int32(n)
+		minReplicas = &n32
+	}
+	v := h.Annotations[HPAOriginalMaxAnnotation]
+	maxReplicas, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || maxReplicas < 1 {
+		return nil, 0, fmt.Errorf("invalid %s annotation %q", HPAOriginalMaxAnnotation, v)
+	}
+	return minReplicas, int32(maxReplicas), nil
+}
+
+// overrideHPA pins h to replicas until the override TTL passes. The original bounds are
+// only recorded by the first override, so renewing one keeps them.
+func (m *Manager) overrideHPA(ctx context.Context, h *autoscalingv2.HorizontalPodAutoscaler, replicas int32, now time.Time) error {
+	ttl := m.opts.HPAOverrideTTL
+	if ttl <= 0 {
+		ttl = DefaultHPAOverrideTTL
+	}
+	until := now.Add(ttl).UTC()
+
+	annotations := map[string]any{
+		HPAOverrideUntilAnnotation: until.Format(time.RFC3339),
+	}
+	if _, overridden := h.Annotations[HPAOverrideUntilAnnotation]; !overridden {
+		annotations[HPAOriginalMinAnnotation] = ""
+		if h.Spec.MinReplicas != nil {
+			annotations[HPAOriginalMinAnnotation] = strconv.Itoa(int(*h.Spec.MinReplicas))
+		}
+		annotations[HPAOriginalMaxAnnotation] = strconv.Itoa(int(h.Spec.MaxReplicas))
+	}
+
+	if err := m.patchHPA(ctx, h.Name, annotations, map[string]any{"minReplicas": replicas, "maxReplicas": replicas}); err != nil {
+		return fmt.Errorf("override horizontalpodautoscaler: %w", err)
+	}
+	slog.InfoContext(ctx, "overrode horizontalpodautoscaler", "namespace", m.namespace, "hpa", h.Name, "replicas", replicas, "until", until)
+	return nil
+}
+
+// restoreHPA puts back the bounds recorded by overrideHPA and clears the override. When
+// the recorded bounds cannot be parsed, the override is cleared with the bounds left
+// pinned, so it is not retried forever, and a single Warning event asks for them to be
+// fixed by hand.
+func (m *Manager) restoreHPA(ctx context.Context, h *autoscalingv2.HorizontalPodAutoscaler) error {
+	var problems []string
+	if v := h.Annotations[HPAOverrideUntilAnnotation]; !validOverrideUntil(v) {
+		problems = append(problems, fmt.Sprintf("invalid %s annotation %q", HPAOverrideUntilAnnotation, v))
+	}
+	minReplicas, maxReplicas, boundsErr := originalBounds(h)
+	if boundsErr != nil {
+		problems = append(problems, boundsErr.Error())
+	}
+
+	annotations := map[string]any{
+		HPAOverrideUntilAnnotation: nil,
+		HPAOriginalMinAnnotation:   nil,
+		HPAOriginalMaxAnnotation:   nil,
+	}
+	var spec map[string]any
+	if boundsErr == nil {
+		spec = map[string]any{"minReplicas": nil, "maxReplicas": maxReplicas}
+		if minReplicas != nil {
+			spec["minReplicas"] = *minReplicas
+		}
+	}
+	if err := m.patchHPA(ctx, h.Name, annotations, spec); err != nil {
+		return fmt.Errorf("restore horizontalpodautoscaler: %w", err)
+	}
+
+	if len(problems) > 0 {
+		note := strings.Join(problems, "; ") + "; cleared the override"
+		if boundsErr != nil {
+			note += fmt.Sprintf(", leaving minReplicas and maxReplicas at %d; set them by hand", h.Spec.MaxReplicas)
+		}
+		slog.WarnContext(ctx, "cleared invalid horizontalpodautoscaler override", "namespace", m.namespace, "hpa", h.Name, "problem", note)
+		m.recorder.Eventf(h, nil, corev1.EventTypeWarning, EventReasonHPAOverrideInvalid, hpaEventAction, "%s", note)
+		if boundsErr != nil {
+			return nil
+		}
+	}
+	slog.InfoContext(ctx, "restored horizontalpodautoscaler", "namespace", m.namespace, "hpa", h.Name,
+		"min_replicas", h.Annotations[HPAOriginalMinAnnotation], "max_replicas", maxReplicas)
+	return nil
+}
+
+// validOverrideUntil reports whether v is a valid HPAOverrideUntilAnnotation value.
+func validOverrideUntil(v string) bool {
+	_, err := time.Parse(time.RFC3339, v)
+	return err == nil
+}
+
+// patchHPA merges annotations and, unless nil, spec into the named HPA.
+func (m *Manager) patchHPA(ctx context.Context, name string, annotations, spec map[string]any) error {
+	body := map[string]any{"metadata": map[string]any{"annotations": annotations}}
+	if spec != nil {
+		body["spec"] = spec
+	}
+	patch, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("build patch: %w", err)
+	}
+	_, err = m.client.AutoscalingV2().HorizontalPodAutoscalers(m.namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// restoreExpiredHPAs restores every HPA override that expired at or before now.
+func (m *Manager) restoreExpiredHPAs(ctx context.Context, now time.Time) {
+	hpas, err := m.hpaLister.HorizontalPodAutoscalers(m.namespace).List(labels.Everything())
+	if err != nil {
+		slog.Warn("list horizontalpodautoscalers", "namespace", m.namespace, "error", err)
+		return
+	}
+	for _, h := range hpas {
+		until, ok := overrideUntil(h)
+		if !ok || until.After(now) {
+			continue
+		}
+		if err := m.restoreHPA(ctx, h); err != nil {
+			slog.Error("restore horizontalpodautoscaler failed", "namespace", m.namespace, "hpa", h.Name, "error", err)
+		}
+	}
+}
+
+// watchHPAOverrides restores expired HPA overrides until the Manager shuts down.
+func (m *Manager) watchHPAOverrides() {
+	ctx := context.Background()
+	t := time.NewTicker(hpaRestoreInterval)
+	defer t.Stop()
+
+	for {
+		m.restoreExpiredHPAs(ctx, time.Now())
+		select {
+		case <-m.stopCh:
+			return
+		case <-t.C:
+		}
+	}
+}
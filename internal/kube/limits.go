@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Annotations overriding Options.Limits for a single Deployment. A value of 0 disables
+// the limit for that Deployment.
+const (
+	CooldownAnnotation       = "replica-manager.io/cooldown"
+	MaxStepAnnotation        = "replica-manager.io/max-step"
+	MaxStepPercentAnnotation = "replica-manager.io/max-step-percent"
+)
+
+// ChangeLimits throttle changes made through SetReplicas. Zero values disable a limit.
+type ChangeLimits struct {
+	// Cooldown is the minimum time between two changes to the same Deployment.
+	Cooldown time.Duration
+	// MaxStep caps how many replicas a single change may add or remove.
+	MaxStep int32
+	// MaxStepPercent caps a single change as a percentage of the current replicas.
+	// Scaling up from zero is not limited by it.
+	MaxStepPercent int32
+}
+
+// Limits returns defaults with the Deployment's annotation overrides applied.
+func (d Deployment) Limits(defaults ChangeLimits) ChangeLimits {
+	l := defaults
+	if d.Cooldown != nil {
+		l.Cooldown = *d.Cooldown
+	}
+	if d.MaxStep != nil {
+		l.MaxStep = *d.MaxStep
+	}
+	if d.MaxStepPercent != nil {
+		l.MaxStepPercent = *d.MaxStepPercent
+	}
+	return l
+}
+
+// CheckLimits returns a *CooldownError when the Deployment last changed less than
+// limits.Cooldown before now, or a *StepError when replicas is too far from the current
+// count. Requests that keep the current count are always allowed; d.Replicas must
+// include changes not yet in the cache (SetReplicas does not patch them at all).
+func (d Deployment) CheckLimits(replicas int32, limits ChangeLimits, lastChange, now time.Time) error {
+	if replicas == d.Replicas {
+		return nil
+	}
+
+	if limits.Cooldown > 0 && !lastChange.IsZero() {
+		if next := lastChange.Add(limits.Cooldown); now.Before(next) {
+			return &CooldownError{Name: d.Name, Cooldown: limits.Cooldown, NextAllowed: next, RetryAfter: next.Sub(now)}
+		}
+	}
+
+	step := replicas - d.Replicas
+	if step < 0 {
+		step = -step
+	}
+	if limits.MaxStep > 0 && step > limits.MaxStep {
+		return &StepError{Name: d.Name, Current: d.Replicas, Requested: replicas, Limit: limits.MaxStep}
+	}
+	if limits.MaxStepPercent > 0 && d.Replicas > 0 && int64(step)*100 > int64(d.Replicas)*int64(limits.MaxStepPercent) {
+		return &StepError{Name: d.Name, Current: d.Replicas, Requested: replicas, Limit: limits.MaxStepPercent, Percent: true}
+	}
+	return nil
+}
+
+// CooldownError reports a change requested before the Deployment's cooldown elapsed.
+type CooldownError struct {
+	Name        string
+	Cooldown    time.Duration
+	NextAllowed time.Time
+	RetryAfter  time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("deployment %q is in a %s cooldown; next change allowed in %s", e.Name, e.Cooldown, e.RetryAfter.Round(time.Second))
+}
+
+// StepError reports a change larger than the Deployment's maximum step.
+type StepError struct {
+	Name      string
+	Current   int32
+	Requested int32
+	Limit     int32
+	// Percent is set when Limit is a percentage of Current.
+	Percent bool
+}
+
+func (e *StepError) Error() string {
+	if e.Percent {
+		return fmt.Sprintf("scaling deployment %q from %d to %d exceeds the maximum step of %d%%", e.Name, e.Current, e.Requested, e.Limit)
+	}
+	return fmt.Sprintf("scaling deployment %q from %d to %d exceeds the maximum step of %d", e.Name, e.Current, e.Requested, e.Limit)
+}
+
+// parseDurationAnnotation returns the non-negative duration stored in the given
+// annotation. Invalid values are logged and ignored, like the bounds annotations.
+func parseDurationAnnotation(d *appsv1.Deployment, key string) *time.Duration {
+	v, ok := d.Annotations[key]
+	if !ok {
+		return nil
+	}
+	out, err := time.ParseDuration(v)
+	if err != nil || out < 0 {
+		slog.Warn("ignoring invalid annotation", "annotation", key, "value", v, "namespace", d.Namespace, "deployment", d.Name)
+		return nil
+	}
+	return &out
+}
@@ -2,37 +2,140 @@ package kube
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/metrics"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v2"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/events"
 )
 
+// DefaultManagedKey is the annotation or label that opts a Deployment into management.
+const DefaultManagedKey = "replica-manager.io/managed"
+
+// Options configures optional Manager behavior.
+type Options struct {
+	// ManagedOnly restricts caching, listing and scaling to Deployments whose
+	// ManagedKey annotation or label is "true". Other Deployments behave as if
+	// they do not exist.
+	ManagedOnly bool
+
+	// ManagedKey overrides DefaultManagedKey.
+	ManagedKey string
+
+	// Observer, if set, is called with every cached Deployment state the informer
+	// delivers (including resyncs), and once when a Deployment leaves the cache,
+	// outside the cache lock.
+	Observer func(Observation)
+
+	// StaleAfter marks the cache stale, and the Manager not ready, once the watch has
+	// failed and the informer has made no progress for this long. 0 disables it.
+	StaleAfter time.Duration
+
+	// Limits throttle SetReplicas per Deployment; annotations can override them.
+	Limits ChangeLimits
+
+	// ReplicaBudget caps the total replicas across cached Deployments; scale-ups past it
+	// return a *BudgetError. Changes made by this process count as soon as they are
+	// patched. 0 disables the budget.
+	ReplicaBudget int64
+
+	// QuotaCheck watches ResourceQuotas and rejects scale-ups whose additional pods
+	// would not fit them.
+	QuotaCheck bool
+
+	// HPACheck watches HorizontalPodAutoscalers and rejects direct scaling of the
+	// Deployments they target unless the caller requests an override (see
+	// WithHPAOverride), which pins the HPA for HPAOverrideTTL (DefaultHPAOverrideTTL
+	// when 0).
+	HPACheck       bool
+	HPAOverrideTTL time.Duration
+
+	// PDBCheck watches PodDisruptionBudgets so DescribeDeployment reports the one
+	// covering each Deployment (see Deployment.CheckPDB).
+	PDBCheck bool
+}
+
+// progressPollInterval is how often the informer is sampled for progress, so that the
+// cache age of a namespace without Deployment changes stays current.
+const progressPollInterval = 5 * time.Second
+
 // Manager implements Store using a client-go shared informer and an in-memory cache.
 type Manager struct {
 	namespace string
 	client    kubernetes.Interface
+	opts      Options
 
 	// informer lifecycle
 	factory  informers.SharedInformerFactory
 	synced   cache.InformerSynced
+	lister   appslisters.DeploymentLister
 	stopCh   chan struct{}
 	stopOnce sync.Once
 
+	// quotaLister is set when Options.QuotaCheck is enabled.
+	quotaLister corelisters.ResourceQuotaLister
+	// hpaLister is set when Options.HPACheck is enabled.
+	hpaLister autoscalinglisters.HorizontalPodAutoscalerLister
+	// pdbLister is set when Options.PDBCheck is enabled.
+	pdbLister policylisters.PodDisruptionBudgetLister
+
+	// Kubernetes Events recorded on scaled Deployments.
+	broadcaster events.EventBroadcaster
+	recorder    events.EventRecorder
+
 	// cache
-	mu       sync.Mutex
-	replicas map[string]int32
+	mu          sync.Mutex
+	deployments map[string]Deployment
+	// lastChange records successful SetReplicas calls, so cooldowns hold before the
+	// informer delivers the updated last-scaled-at annotation.
+	lastChange map[string]time.Time
+	// scaleLocks serialize changes per Deployment (see lockDeployment).
+	scaleLocks map[string]*sync.Mutex
+	// pending holds patched changes the informer has not delivered yet (see
+	// notePending); budgetMu serializes scale-ups against Options.ReplicaBudget.
+	pending  map[string]pendingScale
+	budgetMu sync.Mutex
+
+	// informer activity, for InformerStats
+	statsMu          sync.Mutex
+	lastEvent        time.Time
+	lastResync       time.Time
+	lastWatchError   string
+	lastWatchErrorAt time.Time
+	resourceVersion  func() string
+	// lastProgress is when the cache last observed the API server: initial sync, a
+	// non-resync event, or a progress sample (see sampleProgress).
+	lastProgress time.Time
+	lastRV       string
+	stale        bool // last state logged by watchProgress
 
 	// readiness
 	readyMu sync.Mutex
@@ -42,23 +145,38 @@ type Manager struct {
 // Compile-time interface checks.
 var _ Store = (*Manager)(nil)
 var _ Pinger = (*Manager)(nil)
-
-// NewManager constructs a Manager and starts the Deployment informer in the background.
-// Call Shutdown() to stop the informer.
-func NewManager(namespace string) (*Manager, error) {
-	if namespace == "" {
-		namespace = "default"
-	}
-
+var _ Describer = (*Manager)(nil)
+var _ Inspector = (*Manager)(nil)
+var _ CacheAger = (*Manager)(nil)
+var _ ReplicaCounter = (*Manager)(nil)
+var _ Hibernator = (*Manager)(nil)
+
+// NewClientset builds a Kubernetes client from in-cluster config or the local kubeconfig.
+func NewClientset() (kubernetes.Interface, error) {
 	cfg, err := buildRESTConfig()
 	if err != nil {
 		return nil, err
 	}
+	// Kubernetes calls made with a traced context become child spans.
+	cfg.Wrap(tracing.Transport)
 
 	client, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("create kubernetes client: %w", err)
 	}
+	return client, nil
+}
+
+// NewManager constructs a Manager around client (see NewClientset; tests pass a fake
+// clientset) and starts the Deployment informer in the background. Call Shutdown() to
+// stop the informer.
+func NewManager(client kubernetes.Interface, namespace string, opts Options) (*Manager, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+	if opts.ManagedKey == "" {
+		opts.ManagedKey = DefaultManagedKey
+	}
 
 	// Shared informer scoped to a namespace.
 	factory := informers.NewSharedInformerFactoryWithOptions(
@@ -67,40 +185,103 @@ func NewManager(namespace string) (*Manager, error) {
 		informers.WithNamespace(namespace),
 	)
 
-	deployInformer := factory.Apps().V1().Deployments().Informer()
+	deployments := factory.Apps().V1().Deployments()
+	deployInformer := deployments.Informer()
+
+	broadcaster := events.NewBroadcaster(&events.EventSinkImpl{Interface: client.EventsV1()})
 
 	m := &Manager{
-		namespace: namespace,
-		client:    client,
-		factory:   factory,
-		synced:    deployInformer.HasSynced,
-		stopCh:    make(chan struct{}),
-		replicas:  make(map[string]int32),
+		namespace:       namespace,
+		client:          client,
+		opts:            opts,
+		factory:         factory,
+		synced:          deployInformer.HasSynced,
+		lister:          deployments.Lister(),
+		resourceVersion: deployInformer.LastSyncResourceVersion,
+		stopCh:          make(chan struct{}),
+		broadcaster:     broadcaster,
+		recorder:        broadcaster.NewRecorder(scheme.Scheme, eventsComponent),
+		deployments:     make(map[string]Deployment),
+		lastChange:      make(map[string]time.Time),
+		scaleLocks:      make(map[string]*sync.Mutex),
+		pending:         make(map[string]pendingScale),
+	}
+
+	synced := []cache.InformerSynced{deployInformer.HasSynced}
+	if opts.QuotaCheck {
+		quotas := factory.Core().V1().ResourceQuotas()
+		synced = append(synced, quotas.Informer().HasSynced)
+		m.quotaLister = quotas.Lister()
+	}
+	if opts.HPACheck {
+		hpas := factory.Autoscaling().V2().HorizontalPodAutoscalers()
+		synced = append(synced, hpas.Informer().HasSynced)
+		m.hpaLister = hpas.Lister()
+	}
+	if opts.PDBCheck {
+		pdbs := factory.Policy().V1().PodDisruptionBudgets()
+		synced = append(synced, pdbs.Informer().HasSynced)
+		m.pdbLister = pdbs.Lister()
+	}
+	m.synced = func() bool {
+		for _, hasSynced := range synced {
+			if !hasSynced() {
+				return false
+			}
+		}
+		return true
 	}
 
 	// Register event handlers to keep cache updated.
-	_, err = deployInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    m.onAddOrUpdate,
-		UpdateFunc: func(_, newObj any) { m.onAddOrUpdate(newObj) },
-		DeleteFunc: m.onDelete,
+	_, err := deployInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			metrics.InformerEvents.WithLabelValues("add").Inc()
+			m.noteEvent(false)
+			m.onAddOrUpdate(obj)
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			metrics.InformerEvents.WithLabelValues("update").Inc()
+			m.noteEvent(isResync(oldObj, newObj))
+			m.onAddOrUpdate(newObj)
+		},
+		DeleteFunc: func(obj any) {
+			metrics.InformerEvents.WithLabelValues("delete").Inc()
+			m.noteEvent(false)
+			m.onDelete(obj)
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("add informer handler: %w", err)
 	}
 
-	// Start informers.
+	// Record list/watch failures instead of only logging them (must precede Start).
+	if err := deployInformer.SetWatchErrorHandlerWithContext(m.onWatchError); err != nil {
+		return nil, fmt.Errorf("set watch error handler: %w", err)
+	}
+
+	// Start informers and event delivery.
 	factory.Start(m.stopCh)
+	if err := broadcaster.StartRecordingToSinkWithContext(wait.ContextForChannel(m.stopCh)); err != nil {
+		return nil, fmt.Errorf("start event recording: %w", err)
+	}
 
 	// Wait for initial sync in background; mark ready when synced.
 	go func() {
 		if ok := cache.WaitForCacheSync(m.stopCh, m.synced); !ok {
-			log.Printf("kube cache sync did not complete (namespace=%s)", m.namespace)
+			slog.Error("kube cache sync did not complete", "namespace", m.namespace)
 			return
 		}
+		m.noteProgress()
 		m.readyMu.Lock()
 		m.ready = true
 		m.readyMu.Unlock()
-		log.Printf("kube cache synced (namespace=%s)", m.namespace)
+		metrics.CacheLastSync.SetToCurrentTime()
+		slog.Info("kube cache synced", "namespace", m.namespace)
+
+		if m.hpaLister != nil {
+			go m.watchHPAOverrides()
+		}
+		m.watchProgress()
 	}()
 
 	return m, nil
@@ -110,23 +291,40 @@ func NewManager(namespace string) (*Manager, error) {
 func (m *Manager) Shutdown() {
 	m.stopOnce.Do(func() {
 		close(m.stopCh)
+		m.broadcaster.Shutdown()
 	})
 }
 
-// Ready returns true once the informer cache has synced at least once.
+// Ready returns true once the informer cache has synced at least once, unless the cache
+// has since gone stale (see Options.StaleAfter).
 func (m *Manager) Ready() bool {
 	m.readyMu.Lock()
-	defer m.readyMu.Unlock()
-	return m.ready
+	ready := m.ready
+	m.readyMu.Unlock()
+	return ready && !m.isStale(time.Now())
+}
+
+// CacheAge reports how long ago the cache last observed the API server. It is zero
+// before the initial sync.
+func (m *Manager) CacheAge() time.Duration {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	if m.lastProgress.IsZero() {
+		return 0
+	}
+	return time.Since(m.lastProgress)
 }
 
 // ListDeployments returns cached deployment names.
 func (m *Manager) ListDeployments(ctx context.Context) ([]string, error) {
+	_, span := m.startSpan(ctx, "ListDeployments")
+	defer span.End()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	out := make([]string, 0, len(m.replicas))
-	for name := range m.replicas {
+	out := make([]string, 0, len(m.deployments))
+	for name := range m.deployments {
 		out = append(out, name)
 	}
 	return out, nil
@@ -134,58 +332,560 @@ func (m *Manager) ListDeployments(ctx context.Context) ([]string, error) {
 
 // GetReplicas returns cached desired replicas for the given deployment name.
 func (m *Manager) GetReplicas(ctx context.Context, name string) (int32, bool, error) {
+	_, span := m.startSpan(ctx, "GetReplicas", attribute.String("k8s.deployment.name", name))
+	defer span.End()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	v, ok := m.replicas[name]
-	return v, ok, nil
+	d, ok := m.deployments[name]
+	return d.Replicas, ok, nil
+}
+
+// DescribeDeployment returns the cached state of the given deployment, including the HPA
+// targeting it and the PDB covering it when Options.HPACheck and PDBCheck are enabled.
+func (m *Manager) DescribeDeployment(ctx context.Context, name string) (Deployment, bool, error) {
+	_, span := m.startSpan(ctx, "DescribeDeployment", attribute.String("k8s.deployment.name", name))
+	defer span.End()
+
+	m.mu.Lock()
+	d, ok := m.deployments[name]
+	m.mu.Unlock()
+
+	if ok {
+		if h := m.hpaTargets()[name]; h != nil {
+			d.HPA = newHPA(h)
+		}
+		d.PDB = m.pdbFor(name)
+	}
+	return d, ok, nil
 }
 
 // SetReplicas updates desired replicas in Kubernetes (cache updates asynchronously via informer).
-func (m *Manager) SetReplicas(ctx context.Context, name string, replicas int32) error {
+// The outcome is recorded as an Event on the Deployment, attributed to the Change in ctx.
+func (m *Manager) SetReplicas(ctx context.Context, name string, replicas int32) (err error) {
+	ctx, span := m.startSpan(ctx, "SetReplicas",
+		attribute.String("k8s.deployment.name", name),
+		attribute.Int("replicas", int(replicas)),
+	)
+	defer func() { endSpan(span, err) }()
+
 	if replicas < 0 {
 		return fmt.Errorf("replicas must be >= 0")
 	}
 
-	// Patch spec.replicas only.
-	patch := fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)
+	// Without a cache entry there is nothing to check the change against, so fail
+	// closed. Unmanaged deployments look exactly like missing ones.
+	d, unlock, ok := m.snapshot(ctx, name)
+	if !ok {
+		return apierrors.NewNotFound(appsv1.Resource("deployments"), name)
+	}
+	defer unlock()
+	if replicas == d.Replicas {
+		return nil
+	}
+	defer m.lockBudget()()
+
+	change := ChangeFromContext(ctx)
+	prev := &d.Replicas
+	now := time.Now()
+	hpa, err := m.checkScale(d, replicas, HPAOverrideFromContext(ctx), now)
+	if err != nil {
+		m.recordScaleEvent(name, corev1.EventTypeWarning, EventReasonScaleRejected, change, prev, replicas, err)
+		return err
+	}
 
-	_, err := m.client.AppsV1().Deployments(m.namespace).Patch(
+	// Pin the HPA first, so it does not scale the Deployment back in between.
+	if hpa != nil {
+		if err := m.overrideHPA(ctx, hpa, replicas, now); err != nil {
+			m.recordScaleEvent(name, corev1.EventTypeWarning, EventReasonScaleFailed, change, prev, replicas, err)
+			return err
+		}
+	}
+
+	return m.applyScale(ctx, name, replicas, prev, change, now, nil, "")
+}
+
+// checkScale runs the policy checks for scaling d to replicas: HPA ownership, bounds,
+// change limits, quota and the replica budget. It returns the HPA to pin when override
+// lets the change through one. Hold lockDeployment and lockBudget.
+func (m *Manager) checkScale(d Deployment, replicas int32, override bool, now time.Time) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	var hpa *autoscalingv2.HorizontalPodAutoscaler
+	if d.HPA != nil {
+		if err := checkHPA(d, replicas, override); err != nil {
+			return nil, err
+		}
+		hpa = m.hpaTargets()[d.Name]
+	}
+	if err := d.CheckBounds(replicas); err != nil {
+		return nil, err
+	}
+	if err := d.CheckLimits(replicas, d.Limits(m.opts.Limits), m.lastChangeAt(d), now); err != nil {
+		return nil, err
+	}
+	if m.quotaLister != nil && replicas > d.Replicas {
+		if err := m.checkQuota(d.Name, replicas-d.Replicas); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkBudget(d.Name, replicas); err != nil {
+		return nil, err
+	}
+	return hpa, nil
+}
+
+// applyScale patches spec.replicas and the attribution annotations, and records the
+// outcome. hibernated is written to HibernatedReplicasAnnotation; nil clears it, so a
+// direct change is not undone by a later wake. A non-empty resourceVersion makes the
+// patch fail with a conflict if the Deployment changed since it was read.
+func (m *Manager) applyScale(ctx context.Context, name string, replicas int32, prev *int32, change Change, now time.Time, hibernated *int32, resourceVersion string) error {
+	patch, err := scalePatch(replicas, prev, change, now, hibernated, resourceVersion)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	updated, err := m.client.AppsV1().Deployments(m.namespace).Patch(
 		ctx,
 		name,
 		types.MergePatchType,
-		[]byte(patch),
+		patch,
 		metav1.PatchOptions{},
 	)
+	metrics.PatchDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.PatchErrors.Inc()
+		slog.WarnContext(ctx, "patch deployment replicas failed", "namespace", m.namespace, "deployment", name, "replicas", replicas, "error", err)
+		m.recordScaleEvent(name, corev1.EventTypeWarning, EventReasonScaleFailed, change, prev, replicas, err)
 		return fmt.Errorf("patch deployment replicas: %w", err)
 	}
+	m.mu.Lock()
+	m.lastChange[name] = now
+	m.mu.Unlock()
+	m.notePending(name, replicas, updated.ResourceVersion)
+
+	slog.InfoContext(ctx, "patched deployment replicas", "namespace", m.namespace, "deployment", name, "replicas", replicas, "caller", change.Caller)
+	m.recordScaleEvent(name, corev1.EventTypeNormal, EventReasonScaled, change, prev, replicas, nil)
 	return nil
 }
 
+// snapshot locks changes to the named Deployment (see lockDeployment) and returns its
+// cached state, with the replicas of a change this process patched but the informer
+// has not delivered yet. Reading it under the lock means the checks see every earlier
+// change. The caller must call unlock when found is true.
+func (m *Manager) snapshot(ctx context.Context, name string) (d Deployment, unlock func(), found bool) {
+	if _, found, _ := m.DescribeDeployment(ctx, name); !found {
+		return Deployment{}, nil, false
+	}
+	unlock = m.lockDeployment(name)
+	d, found, _ = m.DescribeDeployment(ctx, name)
+	if !found {
+		unlock()
+		return Deployment{}, nil, false
+	}
+	m.mu.Lock()
+	if p, ok := m.pending[name]; ok {
+		d.Replicas = p.replicas
+	}
+	m.mu.Unlock()
+	return d, unlock, true
+}
+
+// lockDeployment locks changes to the named Deployment and returns the unlock function.
+// It is held from the change limit checks until lastChange is recorded, so concurrent
+// requests cannot all pass a cooldown before any of them is recorded. Locks are kept for
+// the life of the Manager; only cached names reach this point.
+func (m *Manager) lockDeployment(name string) func() {
+	m.mu.Lock()
+	l, ok := m.scaleLocks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		m.scaleLocks[name] = l
+	}
+	m.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// lastChangeAt returns when d was last scaled through the service: the last-scaled-at
+// annotation, which survives restarts, or a more recent change from this process.
+func (m *Manager) lastChangeAt(d Deployment) time.Time {
+	var at time.Time
+	if d.LastScale != nil {
+		at = d.LastScale.At
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t := m.lastChange[d.Name]; t.After(at) {
+		at = t
+	}
+	return at
+}
+
+// scalePatch builds a merge patch setting spec.replicas and the attribution annotations
+// in one request. Unset optional values are patched to null so annotations from an
+// earlier change are not mistaken for this one.
+func scalePatch(replicas int32, prev *int32, change Change, now time.Time, hibernated *int32, resourceVersion string) ([]byte, error) {
+	annotations := map[string]any{
+		LastScaledByAnnotation:       change.Caller,
+		LastScaledAtAnnotation:       now.UTC().Format(time.RFC3339Nano),
+		LastScaleReasonAnnotation:    nil,
+		LastScaleTicketAnnotation:    nil,
+		PreviousReplicasAnnotation:   nil,
+		HibernatedReplicasAnnotation: nil,
+	}
+	if change.Caller == "" {
+		annotations[LastScaledByAnnotation] = nil
+	}
+	if change.Reason != "" {
+		annotations[LastScaleReasonAnnotation] = change.Reason
+	}
+	if change.Ticket != "" {
+		annotations[LastScaleTicketAnnotation] = change.Ticket
+	}
+	if prev != nil {
+		annotations[PreviousReplicasAnnotation] = strconv.Itoa(int(*prev))
+	}
+	if hibernated != nil {
+		annotations[HibernatedReplicasAnnotation] = strconv.Itoa(int(*hibernated))
+	}
+
+	metadata := map[string]any{"annotations": annotations}
+	if resourceVersion != "" {
+		metadata["resourceVersion"] = resourceVersion
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": metadata,
+		"spec":     map[string]any{"replicas": replicas},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build patch: %w", err)
+	}
+	return patch, nil
+}
+
 // Ping verifies Kubernetes API connectivity.
-func (m *Manager) Ping(ctx context.Context) error {
-	_, err := m.client.AppsV1().Deployments(m.namespace).List(ctx, metav1.ListOptions{Limit: 1})
+func (m *Manager) Ping(ctx context.Context) (err error) {
+	ctx, span := m.startSpan(ctx, "Ping")
+	defer func() { endSpan(span, err) }()
+
+	_, err = m.client.AppsV1().Deployments(m.namespace).List(ctx, metav1.ListOptions{Limit: 1})
 	if err != nil {
 		return fmt.Errorf("kubernetes connectivity check failed: %w", err)
 	}
 	return nil
 }
 
+// Snapshot returns a copy of every cached deployment, sorted by name.
+func (m *Manager) Snapshot() []Deployment {
+	m.mu.Lock()
+	out := make([]Deployment, 0, len(m.deployments))
+	for _, d := range m.deployments {
+		out = append(out, d)
+	}
+	m.mu.Unlock()
+
+	hpas := m.hpaTargets()
+	for i := range out {
+		if h := hpas[out[i].Name]; h != nil {
+			out[i].HPA = newHPA(h)
+		}
+		out[i].PDB = m.pdbFor(out[i].Name)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// TotalReplicas sums desired replicas across cached deployments, counting changes this
+// process patched before the informer delivers them.
+func (m *Manager) TotalReplicas() (int64, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var total int64
+	for name, d := range m.deployments {
+		if p, ok := m.pending[name]; ok {
+			total += int64(p.replicas)
+		} else {
+			total += int64(d.Replicas)
+		}
+	}
+	return total, len(m.deployments)
+}
+
+// InformerStats reports the Deployment informer's recent activity.
+func (m *Manager) InformerStats() InformerStats {
+	m.mu.Lock()
+	size := len(m.deployments)
+	m.mu.Unlock()
+
+	m.readyMu.Lock()
+	synced := m.ready
+	m.readyMu.Unlock()
+	stale := m.isStale(time.Now())
+
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	return InformerStats{
+		Namespace:        m.namespace,
+		CacheSize:        size,
+		Synced:           synced,
+		Stale:            stale,
+		ResourceVersion:  m.resourceVersion(),
+		LastEvent:        m.lastEvent,
+		LastResync:       m.lastResync,
+		LastProgress:     m.lastProgress,
+		LastWatchError:   m.lastWatchError,
+		LastWatchErrorAt: m.lastWatchErrorAt,
+	}
+}
+
+// noteEvent records informer activity for InformerStats.
+func (m *Manager) noteEvent(resync bool) {
+	now := time.Now()
+	m.statsMu.Lock()
+	m.lastEvent = now
+	if resync {
+		// Resyncs replay the local store and say nothing about the watch.
+		m.lastResync = now
+	} else {
+		m.lastProgress = now
+	}
+	m.statsMu.Unlock()
+}
+
+// noteProgress records that the cache has just observed the API server.
+func (m *Manager) noteProgress() {
+	m.statsMu.Lock()
+	m.lastProgress = time.Now()
+	m.statsMu.Unlock()
+}
+
+// onWatchError is the informer's watch error handler. The reflector retries on its own;
+// this only records the failure and keeps client-go's default logging.
+func (m *Manager) onWatchError(ctx context.Context, r *cache.Reflector, err error) {
+	m.recordWatchError(err)
+	cache.DefaultWatchErrorHandler(ctx, r, err)
+}
+
+func (m *Manager) recordWatchError(err error) {
+	m.statsMu.Lock()
+	m.lastWatchError = err.Error()
+	m.lastWatchErrorAt = time.Now()
+	m.statsMu.Unlock()
+
+	slog.Warn("deployment watch failed", "namespace", m.namespace, "error", err)
+}
+
+// isStale reports whether the watch has failed since the last progress and the cache has
+// been without progress for longer than StaleAfter. Quiet namespaces with a healthy watch
+// are never stale, since they have no watch errors.
+func (m *Manager) isStale(now time.Time) bool {
+	if m.opts.StaleAfter <= 0 {
+		return false
+	}
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	return m.lastWatchErrorAt.After(m.lastProgress) && now.Sub(m.lastProgress) > m.opts.StaleAfter
+}
+
+// watchProgress samples the informer until shutdown (see sampleProgress) and logs
+// transitions into and out of the stale state.
+func (m *Manager) watchProgress() {
+	interval := progressPollInterval
+	if m.opts.StaleAfter > 0 {
+		interval = min(interval, m.opts.StaleAfter/2)
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-t.C:
+		}
+
+		m.sampleProgress(time.Now())
+		stale := m.isStale(time.Now())
+		m.statsMu.Lock()
+		changed := stale != m.stale
+		m.stale = stale
+		lastErr := m.lastWatchError
+		m.statsMu.Unlock()
+
+		switch {
+		case changed && stale:
+			slog.Error("deployment cache is stale; reporting not ready", "namespace", m.namespace, "stale_after", m.opts.StaleAfter, "last_watch_error", lastErr)
+		case changed:
+			slog.Info("deployment cache recovered", "namespace", m.namespace)
+		}
+	}
+}
+
+// sampleProgress counts now as progress when the informer's resourceVersion changed, or
+// when the watch has not failed since the last progress: a healthy watch delivers every
+// change as it happens, so the cache is current even if nothing changed.
+func (m *Manager) sampleProgress(now time.Time) {
+	rv := m.resourceVersion()
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	if rv != m.lastRV || !m.lastWatchErrorAt.After(m.lastProgress) {
+		m.lastRV = rv
+		m.lastProgress = now
+	}
+}
+
+// isResync reports whether an update carries an unchanged object, which is how periodic
+// resyncs are delivered.
+func isResync(oldObj, newObj any) bool {
+	o, ok1 := oldObj.(*appsv1.Deployment)
+	n, ok2 := newObj.(*appsv1.Deployment)
+	return ok1 && ok2 && o.ResourceVersion == n.ResourceVersion
+}
+
+// startSpan starts a span for a Store method.
+func (m *Manager) startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("k8s.namespace.name", m.namespace))
+	return tracing.Tracer().Start(ctx, "kube.Manager."+method, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err (if any) and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 func (m *Manager) onAddOrUpdate(obj any) {
 	d, ok := obj.(*appsv1.Deployment)
 	if !ok || d == nil {
 		return
 	}
 
-	var rep int32
+	// A deployment that loses its opt-in marker drops out of the cache.
+	if !m.isManaged(d) {
+		m.forget(d.Name)
+		return
+	}
+
+	minReplicas, maxReplicas, invalidBounds := parseBounds(d)
+	entry := Deployment{
+		Name:            d.Name,
+		Labels:          d.Labels,
+		ResourceVersion: d.ResourceVersion,
+		UpdatedAt:       time.Now(),
+		MinReplicas:     minReplicas,
+		MaxReplicas:     maxReplicas,
+		InvalidBounds:   invalidBounds,
+		LastScale:       parseLastScale(d),
+		Cooldown:        parseDurationAnnotation(d, CooldownAnnotation),
+		MaxStep:         parseReplicaAnnotation(d, MaxStepAnnotation),
+		MaxStepPercent:  parseReplicaAnnotation(d, MaxStepPercentAnnotation),
+
+		HibernatedReplicas: parseReplicaAnnotation(d, HibernatedReplicasAnnotation),
+	}
 	if d.Spec.Replicas != nil {
-		rep = *d.Spec.Replicas
+		entry.Replicas = *d.Spec.Replicas
 	}
 
 	m.mu.Lock()
-	m.replicas[d.Name] = rep
+	m.deployments[d.Name] = entry
+	m.observePending(entry)
+	size := len(m.deployments)
 	m.mu.Unlock()
+
+	metrics.CacheSize.Set(float64(size))
+	metrics.CacheLastSync.SetToCurrentTime()
+
+	if m.opts.Observer != nil {
+		o := Observation{
+			Name:       d.Name,
+			Replicas:   entry.Replicas,
+			Generation: d.Generation,
+			ObservedAt: entry.UpdatedAt,
+		}
+		if entry.LastScale != nil {
+			o.ScaledBy = entry.LastScale.By
+			o.ScaledAt = entry.LastScale.At
+		}
+		m.opts.Observer(o)
+	}
+}
+
+// parseReplicaAnnotation returns the non-negative integer stored in the given annotation.
+// Invalid values are logged and ignored so a typo cannot block all scaling.
+func parseReplicaAnnotation(d *appsv1.Deployment, key string) *int32 {
+	v, ok := d.Annotations[key]
+	if !ok {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 0 {
+		slog.Warn("ignoring invalid annotation", "annotation", key, "value", v, "namespace", d.Namespace, "deployment", d.Name)
+		return nil
+	}
+	out := int32(n)
+	return &out
+}
+
+// parseBounds reads MinReplicasAnnotation and MaxReplicasAnnotation. Unlike other
+// annotations, invalid bounds are not ignored, since dropping a bound would allow the
+// very changes it was set to prevent; they are logged and returned as invalid instead.
+func parseBounds(d *appsv1.Deployment) (minReplicas, maxReplicas *int32, invalid string) {
+	for _, key := range []string{MinReplicasAnnotation, MaxReplicasAnnotation} {
+		if v, ok := d.Annotations[key]; ok {
+			if n, err := strconv.ParseInt(v, 10, 32); err != nil || n < 0 {
+				invalid = fmt.Sprintf("%s %q is not a non-negative integer", key, v)
+			}
+		}
+	}
+	if invalid == "" {
+		minReplicas = parseReplicaAnnotation(d, MinReplicasAnnotation)
+		maxReplicas = parseReplicaAnnotation(d, MaxReplicasAnnotation)
+		if minReplicas != nil && maxReplicas != nil && *minReplicas > *maxReplicas {
+			invalid = fmt.Sprintf("%s %d is above %s %d", MinReplicasAnnotation, *minReplicas, MaxReplicasAnnotation, *maxReplicas)
+		}
+	}
+	if invalid != "" {
+		slog.Warn("invalid replica bounds; rejecting changes until fixed", "namespace", d.Namespace, "deployment", d.Name, "problem", invalid)
+	}
+	return minReplicas, maxReplicas, invalid
+}
+
+// parseLastScale reads the attribution annotations written by SetReplicas.
+func parseLastScale(d *appsv1.Deployment) *LastScale {
+	by, hasBy := d.Annotations[LastScaledByAnnotation]
+	at, hasAt := d.Annotations[LastScaledAtAnnotation]
+	if !hasBy && !hasAt {
+		return nil
+	}
+
+	ls := &LastScale{
+		By:               by,
+		Reason:           d.Annotations[LastScaleReasonAnnotation],
+		Ticket:           d.Annotations[LastScaleTicketAnnotation],
+		PreviousReplicas: parseReplicaAnnotation(d, PreviousReplicasAnnotation),
+	}
+	if hasAt {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			slog.Warn("ignoring invalid annotation", "annotation", LastScaledAtAnnotation, "value", at, "namespace", d.Namespace, "deployment", d.Name)
+		}
+		ls.At = t
+	}
+	return ls
+}
+
+// isManaged reports whether the deployment should be exposed through the Store.
+func (m *Manager) isManaged(d *appsv1.Deployment) bool {
+	if !m.opts.ManagedOnly {
+		return true
+	}
+	return d.Annotations[m.opts.ManagedKey] == "true" || d.Labels[m.opts.ManagedKey] == "true"
 }
 
 func (m *Manager) onDelete(obj any) {
@@ -200,9 +900,25 @@ func (m *Manager) onDelete(obj any) {
 		return
 	}
 
+	m.forget(d.Name)
+}
+
+// forget removes a deployment from the cache.
+func (m *Manager) forget(name string) {
 	m.mu.Lock()
-	delete(m.replicas, d.Name)
+	_, cached := m.deployments[name]
+	delete(m.deployments, name)
+	delete(m.lastChange, name)
+	delete(m.pending, name)
+	size := len(m.deployments)
 	m.mu.Unlock()
+
+	metrics.CacheSize.Set(float64(size))
+	metrics.CacheLastSync.SetToCurrentTime()
+
+	if cached && m.opts.Observer != nil {
+		m.opts.Observer(Observation{Name: name, ObservedAt: time.Now(), Deleted: true})
+	}
 }
 
 // buildRESTConfig tries in-cluster config first, then falls back to local kubeconfig.
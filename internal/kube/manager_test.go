@@ -0,0 +1,885 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "test"
+
+func newTestDeployment(name string, replicas int32, annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   testNamespace,
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func mustStartManager(t *testing.T, client *fake.Clientset, opts Options) *Manager {
+	t.Helper()
+	m, err := NewManager(client, testNamespace, opts)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(m.Shutdown)
+
+	waitFor(t, "cache sync", m.Ready)
+	return m
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func hasDeployment(m *Manager, name string) func() bool {
+	return func() bool {
+		_, ok, _ := m.GetReplicas(context.Background(), name)
+		return ok
+	}
+}
+
+func TestManagedOnlyHidesUnmanagedDeployments(t *testing.T) {
+	client := fake.NewClientset(
+		newTestDeployment("managed", 2, map[string]string{DefaultManagedKey: "true"}),
+		newTestDeployment("unmanaged", 3, nil),
+	)
+	m := mustStartManager(t, client, Options{ManagedOnly: true})
+	waitFor(t, "managed deployment", hasDeployment(m, "managed"))
+
+	deps, err := m.ListDeployments(context.Background())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(deps) != 1 || deps[0] != "managed" {
+		t.Fatalf("expected only managed deployment, got %v", deps)
+	}
+
+	err = m.SetReplicas(context.Background(), "unmanaged", 5)
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected not found for unmanaged deployment, got %v", err)
+	}
+}
+
+func TestManagedOnlyFollowsAnnotationChanges(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(newTestDeployment("web", 1, nil))
+	m := mustStartManager(t, client, Options{ManagedOnly: true})
+
+	d, err := client.AppsV1().Deployments(testNamespace).Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	d.Annotations = map[string]string{DefaultManagedKey: "true"}
+	if _, err := client.AppsV1().Deployments(testNamespace).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	waitFor(t, "deployment to appear", hasDeployment(m, "web"))
+
+	d.Annotations = nil
+	if _, err := client.AppsV1().Deployments(testNamespace).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	waitFor(t, "deployment to disappear", func() bool { return !hasDeployment(m, "web")() })
+}
+
+func TestSetReplicasEnforcesAnnotationBounds(t *testing.T) {
+	client := fake.NewClientset(newTestDeployment("api", 3, map[string]string{
+		MinReplicasAnnotation: "2",
+		MaxReplicasAnnotation: "5",
+	}))
+	m := mustStartManager(t, client, Options{})
+	waitFor(t, "deployment", hasDeployment(m, "api"))
+
+	var boundsErr *BoundsError
+	if err := m.SetReplicas(context.Background(), "api", 6); !errors.As(err, &boundsErr) || boundsErr.Bound != MaxReplicasAnnotation {
+		t.Fatalf("expected max bound error, got %v", err)
+	}
+	if err := m.SetReplicas(context.Background(), "api", 1); !errors.As(err, &boundsErr) || boundsErr.Bound != MinReplicasAnnotation {
+		t.Fatalf("expected min bound error, got %v", err)
+	}
+	if err := m.SetReplicas(context.Background(), "api", 4); err != nil {
+		t.Fatalf("expected in-range update to succeed, got %v", err)
+	}
+}
+
+func TestSetReplicasRejectsInvalidBounds(t *testing.T) {
+	client := fake.NewClientset(
+		newTestDeployment("inverted", 3, map[string]string{MinReplicasAnnotation: "5", MaxReplicasAnnotation: "2"}),
+		newTestDeployment("typo", 3, map[string]string{MaxReplicasAnnotation: "1O"}),
+	)
+	m := mustStartManager(t, client, Options{})
+
+	for _, name := range []string{"inverted", "typo"} {
+		var boundsErr *BoundsError
+		if err := m.SetReplicas(context.Background(), name, 4); !errors.As(err, &boundsErr) || boundsErr.Invalid == "" {
+			t.Fatalf("%s: expected invalid bounds error, got %v", name, err)
+		}
+	}
+	if err := m.SetReplicas(context.Background(), "uncached", 3); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected not found for uncached deployment, got %v", err)
+	}
+}
+
+func TestSetReplicasEnforcesCooldown(t *testing.T) {
+	ctx := context.Background()
+	recent := time.Now().Add(-10 * time.Second).UTC().Format(time.RFC3339Nano)
+	client := fake.NewClientset(
+		// Scaled before a restart: the annotation carries the cooldown over.
+		newTestDeployment("restarted", 3, map[string]string{LastScaledAtAnnotation: recent}),
+		newTestDeployment("exempt", 3, map[string]string{LastScaledAtAnnotation: recent, CooldownAnnotation: "0"}),
+		newTestDeployment("fresh", 3, nil),
+	)
+	m := mustStartManager(t, client, Options{Limits: ChangeLimits{Cooldown: time.Minute}})
+	waitFor(t, "deployments", func() bool {
+		return hasDeployment(m, "restarted")() && hasDeployment(m, "exempt")() && hasDeployment(m, "fresh")()
+	})
+
+	var cooldownErr *CooldownError
+	err := m.SetReplicas(ctx, "restarted", 4)
+	if !errors.As(err, &cooldownErr) || cooldownErr.RetryAfter <= 40*time.Second || cooldownErr.RetryAfter > 50*time.Second {
+		t.Fatalf("expected cooldown error with ~50s left, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "restarted", 3); err != nil {
+		t.Fatalf("expected no-op change to be allowed, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "exempt", 4); err != nil {
+		t.Fatalf("expected annotation override to disable the cooldown, got %v", err)
+	}
+
+	if err := m.SetReplicas(ctx, "fresh", 4); err != nil {
+		t.Fatalf("first change: %v", err)
+	}
+	if err := m.SetReplicas(ctx, "fresh", 5); !errors.As(err, &cooldownErr) {
+		t.Fatalf("expected cooldown error for an immediate second change, got %v", err)
+	}
+}
+
+func TestSetReplicasCooldownHoldsUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(newTestDeployment("web", 3, nil))
+	m := mustStartManager(t, client, Options{Limits: ChangeLimits{Cooldown: time.Hour}})
+	// Slow patches widen the window between the cooldown check and recording the change.
+	client.PrependReactor("patch", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(20 * time.Millisecond)
+		return false, nil, nil
+	})
+
+	const callers = 10
+	errs := make(chan error, callers)
+	for i := range callers {
+		go func() { errs <- m.SetReplicas(ctx, "web", int32(4+i)) }()
+	}
+
+	applied := 0
+	for range callers {
+		var cooldownErr *CooldownError
+		switch err := <-errs; {
+		case err == nil:
+			applied++
+		case !errors.As(err, &cooldownErr):
+			t.Fatalf("expected cooldown error, got %v", err)
+		}
+	}
+	if applied != 1 {
+		t.Fatalf("expected exactly one change inside the cooldown, got %d", applied)
+	}
+}
+
+// withholdPatches answers Deployment patches without storing them, so the informer never
+// delivers the change and the cache keeps the old count. It returns the patch count.
+func withholdPatches(client *fake.Clientset) func() int {
+	var mu sync.Mutex
+	patches := 0
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		var patch struct {
+			Spec struct {
+				Replicas int32 `json:"replicas"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &patch); err != nil {
+			return true, nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		patches++
+		d := newTestDeployment(action.(k8stesting.PatchAction).GetName(), patch.Spec.Replicas, nil)
+		d.ResourceVersion = strconv.Itoa(100 + patches)
+		return true, d, nil
+	})
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return patches
+	}
+}
+
+func TestSetReplicasChecksAgainstPendingCount(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(
+		newTestDeployment("web", 3, map[string]string{MaxStepAnnotation: "0"}),
+		newTestDeployment("api", 3, map[string]string{CooldownAnnotation: "0"}),
+	)
+	m := mustStartManager(t, client, Options{Limits: ChangeLimits{Cooldown: time.Hour, MaxStep: 2}})
+	waitFor(t, "deployments", func() bool { return hasDeployment(m, "web")() && hasDeployment(m, "api")() })
+	patches := withholdPatches(client)
+
+	// Reverting to the cached count is a change inside the cooldown, not a no-op.
+	if err := m.SetReplicas(ctx, "web", 10); err != nil {
+		t.Fatalf("first change: %v", err)
+	}
+	var cooldownErr *CooldownError
+	if err := m.SetReplicas(ctx, "web", 3); !errors.As(err, &cooldownErr) {
+		t.Fatalf("expected cooldown error for reverting to the starting count, got %v", err)
+	}
+	// Repeating the pending count is a no-op: nothing is patched and the cooldown is kept.
+	if err := m.SetReplicas(ctx, "web", 10); err != nil {
+		t.Fatalf("expected the pending count to be a no-op, got %v", err)
+	}
+	if n := patches(); n != 1 {
+		t.Fatalf("expected 1 patch, got %d", n)
+	}
+
+	// Steps are measured from the pending count.
+	if err := m.SetReplicas(ctx, "api", 5); err != nil {
+		t.Fatalf("3 -> 5: %v", err)
+	}
+	if err := m.SetReplicas(ctx, "api", 7); err != nil {
+		t.Fatalf("5 -> 7: %v", err)
+	}
+	var stepErr *StepError
+	if err := m.SetReplicas(ctx, "api", 3); !errors.As(err, &stepErr) || stepErr.Current != 7 {
+		t.Fatalf("expected a step error from 7, got %v", err)
+	}
+}
+
+func TestPendingChangeDroppedBySupersedingVersion(t *testing.T) {
+	ctx := context.Background()
+	web := newTestDeployment("web", 3, nil)
+	web.ResourceVersion = "1"
+	client := fake.NewClientset(web)
+	m := mustStartManager(t, client, Options{})
+	waitFor(t, "web", hasDeployment(m, "web"))
+	withholdPatches(client)
+
+	if err := m.SetReplicas(ctx, "web", 10); err != nil {
+		t.Fatalf("SetReplicas: %v", err)
+	}
+	if total, _ := m.TotalReplicas(); total != 10 {
+		t.Fatalf("expected the pending change to be counted, got %d", total)
+	}
+
+	// Another writer's change supersedes the patch that never arrives.
+	web = newTestDeployment("web", 6, nil)
+	web.ResourceVersion = "2"
+	if _, err := client.AppsV1().Deployments(web.Namespace).Update(ctx, web, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	waitFor(t, "superseding version", func() bool {
+		total, _ := m.TotalReplicas()
+		return total == 6
+	})
+}
+
+func TestSetReplicasEnforcesBudgetUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	names := []string{"a", "b", "c", "d", "e"}
+	client := fake.NewClientset()
+	for _, name := range names {
+		if err := client.Tracker().Add(newTestDeployment(name, 1, nil)); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	m := mustStartManager(t, client, Options{ReplicaBudget: 10})
+	// Slow patches keep changes pending while the others are checked.
+	client.PrependReactor("patch", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(20 * time.Millisecond)
+		return false, nil, nil
+	})
+
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		go func() { errs <- m.SetReplicas(ctx, name, 4) }()
+	}
+	applied := 0
+	for range names {
+		var budgetErr *BudgetError
+		switch err := <-errs; {
+		case err == nil:
+			applied++
+		case !errors.As(err, &budgetErr):
+			t.Fatalf("expected budget error, got %v", err)
+		}
+	}
+	// 5 in use; a single +3 fits in the budget of 10, a second would not.
+	if applied != 1 {
+		t.Fatalf("expected exactly one scale-up within the budget, got %d", applied)
+	}
+	if total, _ := m.TotalReplicas(); total != 8 {
+		t.Fatalf("expected the applied change to count at once, got %d", total)
+	}
+}
+
+func TestSetReplicasEnforcesStepLimits(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(
+		newTestDeployment("web", 10, nil),
+		newTestDeployment("api", 10, map[string]string{MaxStepAnnotation: "0", MaxStepPercentAnnotation: "20"}),
+	)
+	m := mustStartManager(t, client, Options{Limits: ChangeLimits{MaxStep: 3}})
+	waitFor(t, "cache sync", m.Ready)
+
+	var stepErr *StepError
+	if err := m.SetReplicas(ctx, "web", 14); !errors.As(err, &stepErr) || stepErr.Percent || stepErr.Limit != 3 {
+		t.Fatalf("expected absolute step error, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "api", 13); !errors.As(err, &stepErr) || !stepErr.Percent || stepErr.Limit != 20 {
+		t.Fatalf("expected percent step error, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "api", 8); err != nil {
+		t.Fatalf("expected a 20%% change to be allowed, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "web", 7); err != nil {
+		t.Fatalf("expected a step of 3 to be allowed, got %v", err)
+	}
+}
+
+func TestSetReplicasChecksResourceQuota(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDeployment("web", 2, nil)
+	d.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	}}
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: testNamespace},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:    resource.MustParse("2"),
+				corev1.ResourceRequestsMemory: resource.MustParse("1Gi"),
+				corev1.ResourcePods:           resource.MustParse("10"),
+			},
+			Used: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:    resource.MustParse("1"),
+				corev1.ResourceRequestsMemory: resource.MustParse("512Mi"),
+				corev1.ResourcePods:           resource.MustParse("2"),
+			},
+		},
+	}
+	// Scoped quotas are not evaluated.
+	scoped := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "best-effort", Namespace: testNamespace},
+		Spec:       corev1.ResourceQuotaSpec{Scopes: []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}},
+		Status:     corev1.ResourceQuotaStatus{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("0")}},
+	}
+	client := fake.NewClientset(d, quota, scoped)
+	m := mustStartManager(t, client, Options{QuotaCheck: true})
+	waitFor(t, "deployment", hasDeployment(m, "web"))
+
+	// +3 pods need 1500m CPU (1 in use of 2) and 768Mi memory (512Mi in use of 1Gi).
+	var quotaErr *QuotaError
+	if err := m.SetReplicas(ctx, "web", 5); !errors.As(err, &quotaErr) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if quotaErr.Quota != "compute" || len(quotaErr.Violations) != 2 ||
+		quotaErr.Violations[0].Resource != "requests.cpu" || quotaErr.Violations[0].Requested.String() != "1500m" ||
+		quotaErr.Violations[1].Resource != "requests.memory" || quotaErr.Violations[1].Requested.String() != "768Mi" {
+		t.Fatalf("unexpected quota error: %v", quotaErr)
+	}
+
+	if err := m.SetReplicas(ctx, "web", 4); err != nil {
+		t.Fatalf("expected a scale-up that fits to succeed, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "web", 1); err != nil {
+		t.Fatalf("expected scale-down to skip the quota check, got %v", err)
+	}
+}
+
+func TestQuotaUsageCountsInitContainersAndOverhead(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	cpu := func(v string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(v)}}
+	}
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: "migrate", Resources: cpu("2")},
+			{Name: "proxy", Resources: cpu("100m"), RestartPolicy: &always},
+		},
+		Containers: []corev1.Container{{Name: "a", Resources: cpu("300m")}, {Name: "b", Resources: cpu("200m")}},
+		Overhead:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+	}
+
+	usage := quotaUsage(spec)
+	got := usage[corev1.ResourceRequestsCPU]
+	if got.Cmp(resource.MustParse("2050m")) != 0 {
+		t.Fatalf("requests.cpu = %s, want 2050m", got.String())
+	}
+	if pods := usage[corev1.ResourcePods]; pods.Value() != 1 {
+		t.Fatalf("pods = %s, want 1", pods.String())
+	}
+}
+
+func TestSetReplicasHPAConflictAndOverride(t *testing.T) {
+	ctx := context.Background()
+	minReplicas := int32(2)
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-hpa", Namespace: testNamespace},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    10,
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: 3, DesiredReplicas: 4},
+	}
+	client := fake.NewClientset(newTestDeployment("web", 3, nil), newTestDeployment("api", 1, nil), hpa)
+	m := mustStartManager(t, client, Options{HPACheck: true, HPAOverrideTTL: time.Minute})
+	waitFor(t, "cache sync", m.Ready)
+
+	d, _, _ := m.DescribeDeployment(ctx, "web")
+	if d.HPA == nil || d.HPA.Name != "web-hpa" || d.HPA.MinReplicas != 2 || d.HPA.MaxReplicas != 10 ||
+		d.HPA.CurrentReplicas != 3 || d.HPA.DesiredReplicas != 4 || d.HPA.Override != nil {
+		t.Fatalf("unexpected hpa: %+v", d.HPA)
+	}
+	if d, _, _ := m.DescribeDeployment(ctx, "api"); d.HPA != nil {
+		t.Fatalf("expected no hpa for api, got %+v", d.HPA)
+	}
+
+	var conflict *HPAConflictError
+	if err := m.SetReplicas(ctx, "web", 5); !errors.As(err, &conflict) || conflict.Override {
+		t.Fatalf("expected hpa conflict, got %v", err)
+	}
+	if err := m.SetReplicas(WithHPAOverride(ctx), "web", 0); !errors.As(err, &conflict) || !conflict.Override {
+		t.Fatalf("expected pinning to 0 to be refused, got %v", err)
+	}
+	if err := m.SetReplicas(ctx, "api", 5); err != nil {
+		t.Fatalf("expected deployment without hpa to scale, got %v", err)
+	}
+
+	if err := m.SetReplicas(WithHPAOverride(ctx), "web", 6); err != nil {
+		t.Fatalf("override: %v", err)
+	}
+	got, err := client.AutoscalingV2().HorizontalPodAutoscalers(testNamespace).Get(ctx, "web-hpa", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.Spec.MinReplicas != 6 || got.Spec.MaxReplicas != 6 ||
+		got.Annotations[HPAOriginalMinAnnotation] != "2" || got.Annotations[HPAOriginalMaxAnnotation] != "10" {
+		t.Fatalf("unexpected pinned hpa: %+v %v", got.Spec, got.Annotations)
+	}
+	waitFor(t, "override in cache", func() bool {
+		d, _, _ := m.DescribeDeployment(ctx, "web")
+		return d.HPA != nil && d.HPA.Override != nil
+	})
+	d, _, _ = m.DescribeDeployment(ctx, "web")
+	if o := d.HPA.Override; *o.OriginalMinReplicas != 2 || o.OriginalMaxReplicas != 10 {
+		t.Fatalf("unexpected override: %+v", o)
+	}
+
+	// Renewing keeps the original bounds.
+	if err := m.SetReplicas(WithHPAOverride(ctx), "web", 7); err != nil {
+		t.Fatalf("renew override: %v", err)
+	}
+	waitFor(t, "renewed override in cache", func() bool {
+		d, _, _ := m.DescribeDeployment(ctx, "web")
+		return d.HPA.MaxReplicas == 7
+	})
+
+	m.restoreExpiredHPAs(ctx, time.Now())
+	if got, _ := client.AutoscalingV2().HorizontalPodAutoscalers(testNamespace).Get(ctx, "web-hpa", metav1.GetOptions{}); got.Spec.MaxReplicas != 7 {
+		t.Fatalf("expected override to hold before it expires, got %+v", got.Spec)
+	}
+	m.restoreExpiredHPAs(ctx, time.Now().Add(2*time.Minute))
+	got, _ = client.AutoscalingV2().HorizontalPodAutoscalers(testNamespace).Get(ctx, "web-hpa", metav1.GetOptions{})
+	if *got.Spec.MinReplicas != 2 || got.Spec.MaxReplicas != 10 || len(got.Annotations) != 0 {
+		t.Fatalf("expected original bounds restored, got %+v %v", got.Spec, got.Annotations)
+	}
+}
+
+func TestRestoreClearsMalformedHPAOverrides(t *testing.T) {
+	ctx := context.Background()
+	pinned := int32(5)
+	newPinnedHPA := func(name string, annotations map[string]string) *autoscalingv2.HorizontalPodAutoscaler {
+		return &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Annotations: annotations},
+			Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{MinReplicas: &pinned, MaxReplicas: 5},
+		}
+	}
+	client := fake.NewClientset(
+		// A malformed expiry counts as expired; the recorded bounds are still restored.
+		newPinnedHPA("bad-until", map[string]string{
+			HPAOverrideUntilAnnotation: "tomorrow",
+			HPAOriginalMinAnnotation:   "2",
+			HPAOriginalMaxAnnotation:   "10",
+		}),
+		// Malformed bounds cannot be restored; the override is cleared regardless.
+		newPinnedHPA("bad-bounds", map[string]string{
+			HPAOverrideUntilAnnotation: "2020-01-01T00:00:00Z",
+			HPAOriginalMaxAnnotation:   "ten",
+		}),
+	)
+	m := mustStartManager(t, client, Options{HPACheck: true})
+
+	get := func(name string) *autoscalingv2.HorizontalPodAutoscaler {
+		h, err := client.AutoscalingV2().HorizontalPodAutoscalers(testNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get %s: %v", name, err)
+		}
+		return h
+	}
+	waitFor(t, "overrides to be cleared", func() bool {
+		return len(get("bad-until").Annotations) == 0 && len(get("bad-bounds").Annotations) == 0
+	})
+	if h := get("bad-until"); *h.Spec.MinReplicas != 2 || h.Spec.MaxReplicas != 10 {
+		t.Fatalf("expected recorded bounds restored, got %+v", h.Spec)
+	}
+	if h := get("bad-bounds"); *h.Spec.MinReplicas != 5 || h.Spec.MaxReplicas != 5 {
+		t.Fatalf("expected bounds left pinned, got %+v", h.Spec)
+	}
+
+	// Once cleared, nothing is left to retry.
+	waitFor(t, "cleared overrides in cache", func() bool {
+		hpas, _ := m.hpaLister.HorizontalPodAutoscalers(testNamespace).List(labels.Everything())
+		for _, h := range hpas {
+			if len(h.Annotations) != 0 {
+				return false
+			}
+		}
+		return len(hpas) == 2
+	})
+	m.restoreExpiredHPAs(ctx, time.Now())
+
+	waitForEvent(t, client, EventReasonHPAOverrideInvalid)
+	time.Sleep(100 * time.Millisecond)
+	list, err := client.EventsV1().Events(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warned := map[string]int{}
+	for _, e := range list.Items {
+		if e.Reason == EventReasonHPAOverrideInvalid && e.Type == corev1.EventTypeWarning && e.Series == nil {
+			warned[e.Regarding.Name]++
+		}
+	}
+	if warned["bad-until"] != 1 || warned["bad-bounds"] != 1 {
+		t.Fatalf("expected one warning per hpa, got %v", warned)
+	}
+}
+
+func TestDescribeDeploymentMatchesPDB(t *testing.T) {
+	ctx := context.Background()
+	web := newTestDeployment("web", 4, nil)
+	web.Spec.Template.Labels = map[string]string{"app": "web"}
+	api := newTestDeployment("api", 2, nil)
+	api.Spec.Template.Labels = map[string]string{"app": "api"}
+	minAvailable := intstr.FromInt32(2)
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-pdb", Namespace: testNamespace},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+		Status: policyv1.PodDisruptionBudgetStatus{ExpectedPods: 4, CurrentHealthy: 4, DesiredHealthy: 2, DisruptionsAllowed: 2},
+	}
+	// A nil selector selects no pods.
+	none := &policyv1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: "a-none", Namespace: testNamespace}}
+	client := fake.NewClientset(web, api, pdb, none)
+	m := mustStartManager(t, client, Options{PDBCheck: true})
+
+	d, _, _ := m.DescribeDeployment(ctx, "web")
+	if d.PDB == nil || d.PDB.Name != "web-pdb" || d.PDB.MinAvailable.IntValue() != 2 || d.PDB.DisruptionsAllowed != 2 {
+		t.Fatalf("unexpected pdb: %+v", d.PDB)
+	}
+	if d, _, _ := m.DescribeDeployment(ctx, "api"); d.PDB != nil {
+		t.Fatalf("expected no pdb for api, got %+v", d.PDB)
+	}
+}
+
+func TestCheckPDB(t *testing.T) {
+	intOrString := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	tests := []struct {
+		name     string
+		pdb      PDB
+		replicas int32
+		wantErr  bool
+	}{
+		{"above minAvailable", PDB{MinAvailable: intOrString(intstr.FromInt32(2)), ExpectedPods: 5}, 3, false},
+		{"at minAvailable", PDB{MinAvailable: intOrString(intstr.FromInt32(2)), ExpectedPods: 5}, 2, true},
+		{"other workloads covered", PDB{MinAvailable: intOrString(intstr.FromInt32(2)), ExpectedPods: 7}, 1, false},
+		{"percent rounds up", PDB{MinAvailable: intOrString(intstr.FromString("50%")), ExpectedPods: 5}, 1, true},
+		{"maxUnavailable zero", PDB{MaxUnavailable: intOrString(intstr.FromInt32(0)), ExpectedPods: 5}, 3, true},
+		{"maxUnavailable percent", PDB{MaxUnavailable: intOrString(intstr.FromString("10%")), ExpectedPods: 5}, 1, false},
+		{"scale to zero", PDB{MinAvailable: intOrString(intstr.FromInt32(2)), ExpectedPods: 5}, 0, false},
+		{"scale up", PDB{MaxUnavailable: intOrString(intstr.FromInt32(0)), ExpectedPods: 5}, 6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Deployment{Name: "web", Replicas: 5, PDB: &tt.pdb}
+			err := d.CheckPDB(tt.replicas)
+			var pdbErr *PDBError
+			if got := errors.As(err, &pdbErr); got != tt.wantErr {
+				t.Fatalf("CheckPDB(%d) = %v, want error %v", tt.replicas, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func waitForEvent(t *testing.T, client *fake.Clientset, reason string) eventsv1.Event {
+	t.Helper()
+	var found eventsv1.Event
+	waitFor(t, reason+" event", func() bool {
+		list, err := client.EventsV1().Events(testNamespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return false
+		}
+		for _, e := range list.Items {
+			if e.Reason == reason {
+				found = e
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+func TestSetReplicasRecordsEvents(t *testing.T) {
+	client := fake.NewClientset(newTestDeployment("api", 3, map[string]string{MaxReplicasAnnotation: "5"}))
+	m := mustStartManager(t, client, Options{})
+	waitFor(t, "deployment", hasDeployment(m, "api"))
+
+	ctx := WithChange(context.Background(), Change{Caller: "alice", Reason: "traffic spike"})
+	if err := m.SetReplicas(ctx, "api", 4); err != nil {
+		t.Fatalf("set replicas: %v", err)
+	}
+	e := waitForEvent(t, client, EventReasonScaled)
+	if e.Type != corev1.EventTypeNormal || e.Regarding.Kind != "Deployment" || e.Regarding.Name != "api" {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	if want := "scaled from 3 to 4 by alice: traffic spike"; e.Note != want {
+		t.Fatalf("expected note %q, got %q", want, e.Note)
+	}
+
+	if err := m.SetReplicas(ctx, "api", 9); err == nil {
+		t.Fatalf("expected bounds error")
+	}
+	e = waitForEvent(t, client, EventReasonScaleRejected)
+	if e.Type != corev1.EventTypeWarning {
+		t.Fatalf("expected warning event, got %+v", e)
+	}
+}
+
+func TestSetReplicasRecordsAttribution(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(newTestDeployment("api", 3, map[string]string{LastScaleTicketAnnotation: "OPS-1"}))
+	m := mustStartManager(t, client, Options{})
+	waitFor(t, "deployment", hasDeployment(m, "api"))
+
+	if err := m.SetReplicas(WithChange(ctx, Change{Caller: "alice", Reason: "load test"}), "api", 5); err != nil {
+		t.Fatalf("set replicas: %v", err)
+	}
+
+	d, err := client.AppsV1().Deployments(testNamespace).Get(ctx, "api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if *d.Spec.Replicas != 5 {
+		t.Fatalf("expected 5 replicas, got %d", *d.Spec.Replicas)
+	}
+	for key, want := range map[string]string{
+		LastScaledByAnnotation:     "alice",
+		LastScaleReasonAnnotation:  "load test",
+		PreviousReplicasAnnotation: "3",
+	} {
+		if got := d.Annotations[key]; got != want {
+			t.Fatalf("annotation %s: expected %q, got %q", key, want, got)
+		}
+	}
+	if _, ok := d.Annotations[LastScaleTicketAnnotation]; ok {
+		t.Fatalf("expected stale ticket annotation to be removed")
+	}
+
+	waitFor(t, "attribution in cache", func() bool {
+		cached, ok, _ := m.DescribeDeployment(ctx, "api")
+		return ok && cached.LastScale != nil && cached.LastScale.By == "alice" && !cached.LastScale.At.IsZero() &&
+			cached.LastScale.PreviousReplicas != nil && *cached.LastScale.PreviousReplicas == 3
+	})
+}
+
+func TestHibernateAndWake(t *testing.T) {
+	ctx := context.Background()
+	api := newTestDeployment("api", 3, nil)
+	api.ResourceVersion = "7" // the fake tracker does not assign versions
+	client := fake.NewClientset(
+		api,
+		newTestDeployment("pinned", 3, map[string]string{MinReplicasAnnotation: "2"}),
+	)
+	m := mustStartManager(t, client, Options{})
+	get := func() *appsv1.Deployment {
+		t.Helper()
+		d, err := client.AppsV1().Deployments(testNamespace).Get(ctx, "api", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		return d
+	}
+	var patch string
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch = string(action.(k8stesting.PatchAction).GetPatch())
+		return false, nil, nil
+	})
+
+	// Hibernation is checked like any other change.
+	var boundsErr *BoundsError
+	if _, err := m.Hibernate(ctx, "pinned"); !errors.As(err, &boundsErr) {
+		t.Fatalf("expected a BoundsError for a deployment with min replicas, got %v", err)
+	}
+
+	if changed, err := m.Hibernate(ctx, "api"); err != nil || !changed {
+		t.Fatalf("hibernate: %v %v", changed, err)
+	}
+	if d := get(); *d.Spec.Replicas != 0 || d.Annotations[HibernatedReplicasAnnotation] != "3" {
+		t.Fatalf("expected 0 replicas with 3 recorded, got %d %v", *d.Spec.Replicas, d.Annotations)
+	}
+	// The patch is conditional on the version the decision was made from.
+	if !strings.Contains(patch, `"resourceVersion":"7"`) {
+		t.Fatalf("expected the patch to carry resourceVersion 7, got %s", patch)
+	}
+	// Decided from the live object, so a repeat before the cache catches up is a no-op.
+	if changed, err := m.Hibernate(ctx, "api"); err != nil || changed {
+		t.Fatalf("expected repeated hibernate to be a no-op, got %v %v", changed, err)
+	}
+	if d := get(); d.Annotations[HibernatedReplicasAnnotation] != "3" {
+		t.Fatalf("expected recorded replicas to be kept, got %v", d.Annotations)
+	}
+	waitFor(t, "hibernation in cache", func() bool {
+		d, _, _ := m.DescribeDeployment(ctx, "api")
+		return d.HibernatedReplicas != nil && *d.HibernatedReplicas == 3
+	})
+
+	if replicas, err := m.Wake(ctx, "api"); err != nil || replicas != 3 {
+		t.Fatalf("wake: %d %v", replicas, err)
+	}
+	if d := get(); *d.Spec.Replicas != 3 || d.Annotations[HibernatedReplicasAnnotation] != "" {
+		t.Fatalf("expected 3 replicas and no recorded count, got %d %v", *d.Spec.Replicas, d.Annotations)
+	}
+	if _, err := m.Wake(ctx, "api"); !errors.Is(err, ErrNotHibernated) {
+		t.Fatalf("expected ErrNotHibernated, got %v", err)
+	}
+	if _, err := m.Hibernate(ctx, "missing"); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// A direct change while hibernated wins over a later wake.
+	if _, err := m.Hibernate(ctx, "api"); err != nil {
+		t.Fatalf("hibernate: %v", err)
+	}
+	waitFor(t, "hibernation in cache", func() bool {
+		d, _, _ := m.DescribeDeployment(ctx, "api")
+		return d.Replicas == 0
+	})
+	if err := m.SetReplicas(ctx, "api", 2); err != nil {
+		t.Fatalf("set replicas: %v", err)
+	}
+	if d := get(); d.Annotations[HibernatedReplicasAnnotation] != "" {
+		t.Fatalf("expected SetReplicas to clear the recorded count, got %v", d.Annotations)
+	}
+}
+
+func TestInformerStatsAndSnapshot(t *testing.T) {
+	client := fake.NewClientset(newTestDeployment("b", 1, nil), newTestDeployment("a", 2, nil))
+	m := mustStartManager(t, client, Options{})
+	waitFor(t, "deployments", func() bool { return len(m.Snapshot()) == 2 })
+
+	snap := m.Snapshot()
+	if snap[0].Name != "a" || snap[1].Name != "b" || snap[0].UpdatedAt.IsZero() {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+
+	st := m.InformerStats()
+	if !st.Synced || st.CacheSize != 2 || st.Namespace != testNamespace || st.LastEvent.IsZero() {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+
+	if total, n := m.TotalReplicas(); total != 3 || n != 2 {
+		t.Fatalf("expected 3 replicas across 2 deployments, got %d across %d", total, n)
+	}
+}
+
+func TestWatchFailureMarksCacheStale(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	m := mustStartManager(t, client, Options{StaleAfter: 50 * time.Millisecond})
+
+	// A quiet namespace with a healthy watch stays ready.
+	time.Sleep(100 * time.Millisecond)
+	if !m.Ready() {
+		t.Fatalf("expected quiet cache to stay ready")
+	}
+
+	m.recordWatchError(errors.New("deployments.apps is forbidden"))
+	waitFor(t, "cache to go stale", func() bool { return !m.Ready() })
+	if st := m.InformerStats(); !st.Stale || st.LastWatchError == "" {
+		t.Fatalf("expected stale stats with watch error, got %+v", st)
+	}
+
+	// Any event from the API server counts as progress.
+	if _, err := client.AppsV1().Deployments(testNamespace).Create(ctx, newTestDeployment("web", 1, nil), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	waitFor(t, "cache to recover", m.Ready)
+	if age := m.CacheAge(); age > time.Second {
+		t.Fatalf("expected fresh cache age, got %s", age)
+	}
+}
+
+func TestCacheAgeStaysCurrentInQuietNamespace(t *testing.T) {
+	m := mustStartManager(t, fake.NewClientset(), Options{})
+	start := time.Now()
+
+	// Without events or resourceVersion changes, a healthy watch still counts.
+	m.sampleProgress(start.Add(time.Minute))
+	if st := m.InformerStats(); !st.LastProgress.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected progress from a healthy watch, got %s", st.LastProgress)
+	}
+
+	// After a watch failure only a resourceVersion change does.
+	m.statsMu.Lock()
+	m.lastWatchErrorAt = start.Add(2 * time.Minute)
+	m.statsMu.Unlock()
+	m.sampleProgress(start.Add(3 * time.Minute))
+	if st := m.InformerStats(); !st.LastProgress.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected no progress after a watch failure, got %s", st.LastProgress)
+	}
+}
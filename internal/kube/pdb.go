@@ -0,0 +1,124 @@
+package kube
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// PDB summarizes the PodDisruptionBudget covering a Deployment's pods.
+type PDB struct {
+	Name           string
+	MinAvailable   *intstr.IntOrString
+	MaxUnavailable *intstr.IntOrString
+
+	// From the PDB's status, as last computed by the disruption controller.
+	ExpectedPods       int32
+	CurrentHealthy     int32
+	DesiredHealthy     int32
+	DisruptionsAllowed int32
+}
+
+// AllowedDisruptions returns how many of expected healthy pods the PDB would let be
+// evicted, rounding percentages up as the disruption controller does.
+func (p PDB) AllowedDisruptions(expected int32) int32 {
+	switch {
+	case p.MaxUnavailable != nil:
+		n, err := intstr.GetScaledValueFromIntOrPercent(p.MaxUnavailable, int(expected), true)
+		if err != nil {
+			return 0
+		}
+		return min(int32(n), expected)
+	case p.MinAvailable != nil:
+		n, err := intstr.GetScaledValueFromIntOrPercent(p.MinAvailable, int(expected), true)
+		if err != nil {
+			return 0
+		}
+		return max(expected-int32(n), 0)
+	default:
+		return expected
+	}
+}
+
+// PDBError reports a scale-down after which the Deployment's PDB would allow no
+// evictions, blocking node drains.
+type PDBError struct {
+	Name     string
+	PDB      PDB
+	Replicas int32
+	// ExpectedPods is the number of pods the PDB would cover after the change.
+	ExpectedPods int32
+}
+
+func (e *PDBError) Error() string {
+	return fmt.Sprintf("scaling deployment %q to %d replicas would leave PodDisruptionBudget %q allowing no disruptions (%d pods covered)",
+		e.Name, e.Replicas, e.PDB.Name, e.ExpectedPods)
+}
+
+// CheckPDB returns a *PDBError when scaling d down to replicas would leave its PDB
+// allowing no disruptions. Pods of other workloads the PDB covers are counted; scaling
+// everything it covers to zero leaves nothing to evict and is allowed.
+func (d Deployment) CheckPDB(replicas int32) error {
+	if d.PDB == nil || replicas >= d.Replicas {
+		return nil
+	}
+	expected := replicas
+	if others := d.PDB.ExpectedPods - d.Replicas; others > 0 {
+		expected += others
+	}
+	if expected == 0 || d.PDB.AllowedDisruptions(expected) > 0 {
+		return nil
+	}
+	return &PDBError{Name: d.Name, PDB: *d.PDB, Replicas: replicas, ExpectedPods: expected}
+}
+
+// pdbFor returns the PDB selecting the named Deployment's pod template, or nil. Pods
+// covered by several PDBs cannot be evicted at all; the first by name is reported.
+func (m *Manager) pdbFor(name string) *PDB {
+	if m.pdbLister == nil {
+		return nil
+	}
+	d, err := m.lister.Deployments(m.namespace).Get(name)
+	if err != nil {
+		return nil
+	}
+	pdbs, err := m.pdbLister.PodDisruptionBudgets(m.namespace).List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+	sort.Slice(pdbs, func(i, j int) bool { return pdbs[i].Name < pdbs[j].Name })
+
+	podLabels := labels.Set(d.Spec.Template.Labels)
+	for _, p := range pdbs {
+		// A nil selector selects no pods in policy/v1; an empty one selects all.
+		if p.Spec.Selector == nil {
+			continue
+		}
+		sel, err := metav1.LabelSelectorAsSelector(p.Spec.Selector)
+		if err != nil {
+			slog.Debug("skipping poddisruptionbudget with invalid selector", "namespace", m.namespace, "pdb", p.Name, "error", err)
+			continue
+		}
+		if sel.Matches(podLabels) {
+			return newPDB(p)
+		}
+	}
+	return nil
+}
+
+func newPDB(p *policyv1.PodDisruptionBudget) *PDB {
+	return &PDB{
+		Name:               p.Name,
+		MinAvailable:       p.Spec.MinAvailable,
+		MaxUnavailable:     p.Spec.MaxUnavailable,
+		ExpectedPods:       p.Status.ExpectedPods,
+		CurrentHealthy:     p.Status.CurrentHealthy,
+		DesiredHealthy:     p.Status.DesiredHealthy,
+		DisruptionsAllowed: p.Status.DisruptionsAllowed,
+	}
+}
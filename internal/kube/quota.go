@@ -0,0 +1,147 @@
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// QuotaError reports a scale-up whose additional pods would not fit a ResourceQuota.
+type QuotaError struct {
+	Name       string
+	Quota      string
+	Violations []QuotaViolation
+}
+
+// QuotaViolation describes one quota resource the scale-up would exceed.
+type QuotaViolation struct {
+	Resource string
+	Hard     resource.Quantity
+	Used     resource.Quantity
+	// Requested is the additional amount the new pods need.
+	Requested resource.Quantity
+}
+
+func (e *QuotaError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: requested %s, used %s, limited to %s", v.Resource, v.Requested.String(), v.Used.String(), v.Hard.String()))
+	}
+	return fmt.Sprintf("scaling deployment %q would exceed ResourceQuota %q (%s)", e.Name, e.Quota, strings.Join(parts, "; "))
+}
+
+// checkQuota returns a *QuotaError when adding pods replicas of the named Deployment
+// would exceed a ResourceQuota in the namespace. Quotas with scopes are not evaluated,
+// since matching them requires pod-level details the pre-flight does not model.
+func (m *Manager) checkQuota(name string, pods int32) error {
+	d, err := m.lister.Deployments(m.namespace).Get(name)
+	if err != nil {
+		return nil // the patch reports missing deployments
+	}
+	quotas, err := m.quotaLister.ResourceQuotas(m.namespace).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("list resource quotas: %w", err)
+	}
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Name < quotas[j].Name })
+
+	need := quotaUsage(d.Spec.Template.Spec)
+	for _, q := range quotas {
+		if len(q.Spec.Scopes) > 0 || q.Spec.ScopeSelector != nil {
+			continue
+		}
+
+		var violations []QuotaViolation
+		for _, res := range sortedResourceNames(q.Status.Hard) {
+			perPod, ok := need[res]
+			if !ok {
+				continue
+			}
+			hard := q.Status.Hard[res]
+			used := q.Status.Used[res]
+			requested := resource.NewMilliQuantity(perPod.MilliValue()*int64(pods), perPod.Format)
+			if used.MilliValue()+requested.MilliValue() > hard.MilliValue() {
+				violations = append(violations, QuotaViolation{Resource: string(res), Hard: hard, Used: used, Requested: *requested})
+			}
+		}
+		if len(violations) > 0 {
+			return &QuotaError{Name: name, Quota: q.Name, Violations: violations}
+		}
+	}
+	return nil
+}
+
+// quotaUsage returns what one pod with spec is charged against a ResourceQuota, keyed by
+// quota resource name (pods, requests.cpu, limits.memory, ...).
+func quotaUsage(spec corev1.PodSpec) corev1.ResourceList {
+	usage := corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")}
+
+	requests := podResources(spec, func(c corev1.Container) corev1.ResourceList {
+		// Containers without requests are defaulted to their limits.
+		out := corev1.ResourceList{}
+		for name, q := range c.Resources.Limits {
+			out[name] = q
+		}
+		for name, q := range c.Resources.Requests {
+			out[name] = q
+		}
+		return out
+	})
+	for name, q := range requests {
+		usage["requests."+name] = q
+		if name == corev1.ResourceCPU || name == corev1.ResourceMemory || name == corev1.ResourceEphemeralStorage {
+			usage[name] = q
+		}
+	}
+	limits := podResources(spec, func(c corev1.Container) corev1.ResourceList { return c.Resources.Limits })
+	for name, q := range limits {
+		usage["limits."+name] = q
+	}
+	return usage
+}
+
+// podResources sums containers and restartable (sidecar) init containers, takes the
+// larger of that and any single init container, and adds the pod overhead.
+func podResources(spec corev1.PodSpec, get func(corev1.Container) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	add := func(list corev1.ResourceList) {
+		for name, q := range list {
+			sum := total[name]
+			sum.Add(q)
+			total[name] = sum
+		}
+	}
+
+	for _, c := range spec.Containers {
+		add(get(c))
+	}
+	for _, c := range spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			add(get(c))
+		}
+	}
+	for _, c := range spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			continue
+		}
+		for name, q := range get(c) {
+			if cur, ok := total[name]; !ok || q.Cmp(cur) > 0 {
+				total[name] = q
+			}
+		}
+	}
+	add(spec.Overhead)
+	return total
+}
+
+func sortedResourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
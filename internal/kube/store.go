@@ -1,6 +1,29 @@
 package kube
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Annotations read from Deployments to bound the replica counts accepted by SetReplicas.
+const (
+	MinReplicasAnnotation = "replica-manager.io/min-replicas"
+	MaxReplicasAnnotation = "replica-manager.io/max-replicas"
+)
+
+// Annotations written by SetReplicas alongside spec.replicas to record who scaled a
+// Deployment and why.
+const (
+	LastScaledByAnnotation     = "replica-manager.io/last-scaled-by"
+	LastScaledAtAnnotation     = "replica-manager.io/last-scaled-at"
+	LastScaleReasonAnnotation  = "replica-manager.io/last-scale-reason"
+	LastScaleTicketAnnotation  = "replica-manager.io/last-scale-ticket"
+	PreviousReplicasAnnotation = "replica-manager.io/previous-replicas"
+)
 
 // Store provides cached reads and write operations against Kubernetes Deployments.
 // Reads should be served from cache (informer), not direct API calls.
@@ -22,3 +45,174 @@ type Store interface {
 type Pinger interface {
 	Ping(ctx context.Context) error
 }
+
+// Describer is optional. It returns the full cached view of a single deployment.
+type Describer interface {
+	DescribeDeployment(ctx context.Context, name string) (Deployment, bool, error)
+}
+
+// CacheAger is optional. CacheAge reports how long ago the cache last observed the API
+// server, so callers can judge how fresh cached reads are.
+type CacheAger interface {
+	CacheAge() time.Duration
+}
+
+// ReplicaCounter is optional. TotalReplicas sums the desired replicas of every cached
+// Deployment, for namespace-wide budgets.
+type ReplicaCounter interface {
+	TotalReplicas() (total int64, deployments int)
+}
+
+// Inspector is optional. It exposes cache internals for debugging.
+type Inspector interface {
+	// Snapshot returns a copy of every cached deployment.
+	Snapshot() []Deployment
+	// InformerStats reports the health of the Deployment informer.
+	InformerStats() InformerStats
+}
+
+// InformerStats describes the Deployment informer's recent activity.
+type InformerStats struct {
+	Namespace string
+	CacheSize int
+	Synced    bool
+	// Stale is set when the watch is failing and the cache has not made progress for
+	// longer than Options.StaleAfter.
+	Stale bool
+	// ResourceVersion is the last resourceVersion the informer listed or watched.
+	ResourceVersion string
+	// LastEvent is the time of the last add, update or delete; LastResync the time of
+	// the last periodic resync (an update without a resourceVersion change).
+	LastEvent  time.Time
+	LastResync time.Time
+	// LastProgress is the last time the cache observed the API server.
+	LastProgress time.Time
+	// LastWatchError is the most recent list/watch failure reported by the reflector.
+	LastWatchError   string
+	LastWatchErrorAt time.Time
+}
+
+// Deployment is the cached state of a Deployment as seen by the informer.
+type Deployment struct {
+	Name     string
+	Replicas int32
+	Labels   map[string]string
+
+	// ResourceVersion and UpdatedAt identify the object version the entry was built from
+	// and when the cache last stored it.
+	ResourceVersion string
+	UpdatedAt       time.Time
+
+	// Bounds from MinReplicasAnnotation / MaxReplicasAnnotation (nil when unset).
+	MinReplicas *int32
+	MaxReplicas *int32
+	// InvalidBounds describes bound annotations that cannot be applied: a value that is
+	// not a non-negative integer, or a minimum above the maximum. CheckBounds rejects
+	// every change while it is set.
+	InvalidBounds string
+
+	// LastScale is parsed from the attribution annotations; nil when the Deployment
+	// has never been scaled through the service.
+	LastScale *LastScale
+
+	// Overrides of Options.Limits from CooldownAnnotation, MaxStepAnnotation and
+	// MaxStepPercentAnnotation (nil when unset); see Limits.
+	Cooldown       *time.Duration
+	MaxStep        *int32
+	MaxStepPercent *int32
+
+	// HPA is the HorizontalPodAutoscaler targeting the Deployment, when
+	// Options.HPACheck is enabled and one exists.
+	HPA *HPA
+
+	// PDB is the PodDisruptionBudget covering the Deployment's pods, when
+	// Options.PDBCheck is enabled and one matches.
+	PDB *PDB
+
+	// HibernatedReplicas is the count recorded by Hibernate, nil when the Deployment is
+	// not hibernated.
+	HibernatedReplicas *int32
+}
+
+// Observation is a Deployment state delivered by the informer, passed to
+// Options.Observer.
+type Observation struct {
+	Name       string
+	Replicas   int32
+	Generation int64
+	ObservedAt time.Time
+	// ScaledBy and ScaledAt come from the attribution annotations, if present.
+	ScaledBy string
+	ScaledAt time.Time
+	// Deleted is set, with only Name and ObservedAt, when the Deployment leaves the
+	// cache because it was deleted or is no longer managed.
+	Deleted bool
+}
+
+// LastScale records the most recent change made through SetReplicas.
+type LastScale struct {
+	By               string
+	At               time.Time
+	Reason           string
+	Ticket           string
+	PreviousReplicas *int32
+}
+
+// CheckBounds returns a *BoundsError when replicas falls outside the deployment's bounds,
+// or when the bounds themselves are invalid.
+func (d Deployment) CheckBounds(replicas int32) error {
+	if d.InvalidBounds != "" {
+		return &BoundsError{Name: d.Name, Requested: replicas, Invalid: d.InvalidBounds}
+	}
+	if d.MinReplicas != nil && replicas < *d.MinReplicas {
+		return &BoundsError{Name: d.Name, Bound: MinReplicasAnnotation, Limit: *d.MinReplicas, Requested: replicas}
+	}
+	if d.MaxReplicas != nil && replicas > *d.MaxReplicas {
+		return &BoundsError{Name: d.Name, Bound: MaxReplicasAnnotation, Limit: *d.MaxReplicas, Requested: replicas}
+	}
+	return nil
+}
+
+// IsRejection reports whether err, from SetReplicas or the checks it runs, refused a
+// change by policy rather than failing to apply it. Deployments that are not cached
+// count as refused.
+func IsRejection(err error) bool {
+	var (
+		boundsErr   *BoundsError
+		stepErr     *StepError
+		cooldownErr *CooldownError
+		quotaErr    *QuotaError
+		hpaErr      *HPAConflictError
+		pdbErr      *PDBError
+		budgetErr   *BudgetError
+	)
+	return apierrors.IsNotFound(err) ||
+		errors.As(err, &boundsErr) ||
+		errors.As(err, &stepErr) ||
+		errors.As(err, &cooldownErr) ||
+		errors.As(err, &quotaErr) ||
+		errors.As(err, &hpaErr) ||
+		errors.As(err, &pdbErr) ||
+		errors.As(err, &budgetErr)
+}
+
+// BoundsError reports a replica count rejected by a per-deployment bound.
+type BoundsError struct {
+	Name      string
+	Bound     string // annotation that defines the violated bound
+	Limit     int32
+	Requested int32
+	// Invalid is set, instead of Bound and Limit, when the bound annotations cannot be
+	// applied (see Deployment.InvalidBounds).
+	Invalid string
+}
+
+func (e *BoundsError) Error() string {
+	if e.Invalid != "" {
+		return fmt.Sprintf("deployment %q has invalid replica bounds (%s); fix the annotations before scaling it", e.Name, e.Invalid)
+	}
+	if e.Bound == MinReplicasAnnotation {
+		return fmt.Sprintf("replicas %d for deployment %q is below the minimum of %d (%s)", e.Requested, e.Name, e.Limit, e.Bound)
+	}
+	return fmt.Sprintf("replicas %d for deployment %q is above the maximum of %d (%s)", e.Requested, e.Name, e.Limit, e.Bound)
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "debug")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.With("component", "test").InfoContext(ctx, "hello")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	if rec["request_id"] != "abc123" || rec["component"] != "test" || rec["msg"] != "hello" {
+		t.Fatalf("unexpected record %v", rec)
+	}
+}
+
+func TestNewRejectsUnknownFormatAndLevel(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	if _, err := New(&bytes.Buffer{}, FormatText, "verbose"); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+}
@@ -0,0 +1,104 @@
+// Package metrics defines the Prometheus metrics exported by the service.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "replica_manager"
+
+// Registry holds every metric exported by the service. A dedicated registry keeps
+// tests isolated from metrics registered by dependencies on the default registry.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts handled requests by listener, route template, method and status code.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by server, route, method and status code.",
+	}, []string{"server", "route", "method", "code"})
+
+	// HTTPRequestDuration observes request latency with the same labels as HTTPRequests.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by server, route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"server", "route", "method", "code"})
+
+	// RejectedRequests counts API requests rejected by rate or concurrency limits.
+	RejectedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_rejected_requests_total",
+		Help:      "API requests rejected by rate or concurrency limits, by reason.",
+	}, []string{"reason"})
+
+	// TLSHandshakeFailures counts failed TLS handshakes on the API listener.
+	TLSHandshakeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tls_handshake_failures_total",
+		Help:      "Failed TLS handshakes on the API listener, by reason.",
+	}, []string{"reason"})
+
+	// InformerEvents counts Deployment informer events by type (add, update, delete).
+	InformerEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "informer_events_total",
+		Help:      "Deployment informer events, by event type.",
+	}, []string{"event"})
+
+	// CacheSize is the number of Deployments in the replica cache.
+	CacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_deployments",
+		Help:      "Number of Deployments in the replica cache.",
+	})
+
+	// CacheLastSync is the time of the last informer sync or event.
+	CacheLastSync = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_last_sync_timestamp_seconds",
+		Help:      "Unix time of the last informer sync, resync or event.",
+	})
+
+	// PatchDuration observes Kubernetes PATCH latency for replica updates.
+	PatchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kube_patch_duration_seconds",
+		Help:      "Latency of Kubernetes PATCH requests for replica updates.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// PatchErrors counts failed Kubernetes PATCH requests for replica updates.
+	PatchErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kube_patch_errors_total",
+		Help:      "Failed Kubernetes PATCH requests for replica updates.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		RejectedRequests,
+		TLSHandshakeFailures,
+		InformerEvents,
+		CacheSize,
+		CacheLastSync,
+		PatchDuration,
+		PatchErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRegisteredWithLabels(t *testing.T) {
+	// Touch each vector so it is present in the exposition output.
+	HTTPRequests.WithLabelValues("api", "/api/v1/deployments", "GET", "200").Inc()
+	HTTPRequestDuration.WithLabelValues("api", "/api/v1/deployments", "GET", "200").Observe(0.01)
+	RejectedRequests.WithLabelValues("rate_limit_write").Inc()
+	TLSHandshakeFailures.WithLabelValues("unknown_ca").Inc()
+	InformerEvents.WithLabelValues("add").Inc()
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+
+	for _, want := range []string{
+		`replica_manager_http_requests_total{code="200",method="GET",route="/api/v1/deployments",server="api"}`,
+		`replica_manager_http_request_duration_seconds_bucket{code="200",method="GET",route="/api/v1/deployments",server="api",le="0.01"}`,
+		`replica_manager_http_rejected_requests_total{reason="rate_limit_write"}`,
+		`replica_manager_tls_handshake_failures_total{reason="unknown_ca"}`,
+		`replica_manager_informer_events_total{event="add"}`,
+		`replica_manager_cache_deployments `,
+		`replica_manager_cache_last_sync_timestamp_seconds `,
+		`replica_manager_kube_patch_duration_seconds_count `,
+		`replica_manager_kube_patch_errors_total `,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Rule scales a Deployment, or every Deployment matching a label selector, to a fixed
+// replica count on a cron schedule.
+type Rule struct {
+	Name       string `json:"name"`
+	Deployment string `json:"deployment,omitempty"`
+	Selector   string `json:"selector,omitempty"`
+	Replicas   int32  `json:"replicas"`
+	// Schedule is a standard five-field cron expression (or a descriptor like @daily).
+	Schedule string `json:"schedule"`
+	// Timezone is an IANA zone name; empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// rulesFile is the on-disk format of SCHEDULE_FILE (YAML or JSON).
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// compiledRule is a validated Rule ready to be evaluated.
+type compiledRule struct {
+	Rule
+	schedule cron.Schedule
+	location *time.Location
+	selector labels.Selector // nil when the rule targets a single deployment
+}
+
+// LoadRules reads and validates rules from a YAML or JSON file.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schedule file: %w", err)
+	}
+	var f rulesFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("parse schedule file: %w", err)
+	}
+	if _, err := compile(f.Rules); err != nil {
+		return nil, err
+	}
+	return f.Rules, nil
+}
+
+func compile(rules []Rule) ([]compiledRule, error) {
+	seen := make(map[string]bool, len(rules))
+	out := make([]compiledRule, 0, len(rules))
+
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("schedule rule %d: name is required", i)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("schedule rule %q: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+
+		if (r.Deployment == "") == (r.Selector == "") {
+			return nil, fmt.Errorf("schedule rule %q: exactly one of deployment or selector is required", r.Name)
+		}
+		if r.Replicas < 0 {
+			return nil, fmt.Errorf("schedule rule %q: replicas must be >= 0", r.Name)
+		}
+
+		c := compiledRule{Rule: r, location: time.UTC}
+		sched, err := cron.ParseStandard(r.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("schedule rule %q: schedule: %w", r.Name, err)
+		}
+		c.schedule = sched
+		if r.Timezone != "" {
+			loc, err := time.LoadLocation(r.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("schedule rule %q: timezone: %w", r.Name, err)
+			}
+			c.location = loc
+		}
+		if r.Selector != "" {
+			sel, err := labels.Parse(r.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("schedule rule %q: selector: %w", r.Name, err)
+			}
+			c.selector = sel
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// next returns the first scheduled time strictly after t.
+func (c compiledRule) next(t time.Time) time.Time {
+	return c.schedule.Next(t.In(c.location))
+}
+
+// lastBetween returns the latest scheduled time in (from, to], or the zero time.
+func (c compiledRule) lastBetween(from, to time.Time) time.Time {
+	var last time.Time
+	for t := c.next(from); !t.IsZero() && !t.After(to); t = c.next(t) {
+		last = t
+	}
+	return last
+}
@@ -0,0 +1,410 @@
+// Package scheduler applies cron-style replica rules through the kube.Store.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/config"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/clock"
+)
+
+// Caller is the identity attributed to scheduled changes in audit records, Events and
+// annotations.
+const Caller = "scheduler"
+
+// Options configures a Scheduler.
+type Options struct {
+	Store     kube.Store
+	Namespace string
+	// Auditor records scheduled writes; nil disables auditing.
+	Auditor *audit.Logger
+	// CatchUpWindow bounds how far back missed runs are applied on start; 0 disables
+	// catch-up.
+	CatchUpWindow time.Duration
+	// Freezes refuses scheduled changes during freeze windows unless Caller is allowed.
+	Freezes *freeze.Calendar
+	// Approvals refuses scheduled changes that would need a second approver, since a
+	// scheduled run cannot wait for one.
+	Approvals approval.Rules
+	// PDBMode is config.PDBModeReject to refuse scheduled scale-downs that would leave a
+	// PodDisruptionBudget allowing no disruptions, or config.PDBModeWarn to log them.
+	PDBMode string
+	// Clock defaults to the real clock; tests inject a fake one.
+	Clock clock.Clock
+}
+
+// Scheduler evaluates rules and applies them when due.
+type Scheduler struct {
+	opts  Options
+	rules []compiledRule
+
+	mu     sync.Mutex
+	status map[string]*RuleStatus
+}
+
+// RuleStatus reports a rule and its run times.
+type RuleStatus struct {
+	Rule
+	NextRun    time.Time
+	LastRun    time.Time
+	LastResult string
+	LastError  string
+}
+
+// Run results.
+const (
+	ResultApplied = "applied"
+	ResultSkipped = "skipped"
+	ResultFailed  = "failed"
+)
+
+// New validates rules and returns a Scheduler. Call Run to start it.
+func New(rules []Rule, opts Options) (*Scheduler, error) {
+	compiled, err := compile(rules)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+
+	s := &Scheduler{
+		opts:   opts,
+		rules:  compiled,
+		status: make(map[string]*RuleStatus, len(compiled)),
+	}
+	now := opts.Clock.Now()
+	for _, r := range compiled {
+		s.status[r.Name] = &RuleStatus{Rule: r.Rule, NextRun: r.next(now)}
+	}
+	return s, nil
+}
+
+// Status returns every rule with its next and last run, in configuration order.
+func (s *Scheduler) Status() []RuleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]RuleStatus, 0, len(s.rules))
+	for _, r := range s.rules {
+		out = append(out, *s.status[r.Name])
+	}
+	return out
+}
+
+// Run waits for the store to become ready, applies missed runs, then fires rules on
+// schedule until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.rules) == 0 {
+		return
+	}
+
+	for !s.opts.Store.Ready() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.opts.Clock.After(time.Second):
+		}
+	}
+
+	now := s.opts.Clock.Now()
+	s.catchUp(ctx, now)
+	s.resetNext(now)
+
+	for {
+		due := s.nextDue()
+		if due.IsZero() {
+			<-ctx.Done()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.opts.Clock.After(due.Sub(s.opts.Clock.Now())):
+		}
+		s.runDue(ctx, s.opts.Clock.Now())
+	}
+}
+
+// catchUp applies, for each target deployment, the latest run missed in the catch-up
+// window. When several rules missed a run for the same deployment, the latest scheduled
+// time wins, ties going to the rule listed last. A run is skipped if the deployment was
+// scaled through the service after it was due, so manual overrides are kept.
+func (s *Scheduler) catchUp(ctx context.Context, now time.Time) {
+	if s.opts.CatchUpWindow <= 0 {
+		return
+	}
+
+	type pending struct {
+		rule compiledRule
+		at   time.Time
+	}
+	latest := make(map[string]pending)
+	for _, r := range s.rules {
+		at := r.lastBetween(now.Add(-s.opts.CatchUpWindow), now)
+		if at.IsZero() {
+			continue
+		}
+		targets, err := s.targets(ctx, r)
+		if err != nil {
+			s.record(r, at, ResultFailed, err)
+			continue
+		}
+		for _, name := range targets {
+			if p, ok := latest[name]; !ok || !at.Before(p.at) {
+				latest[name] = pending{rule: r, at: at}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := latest[name]
+		if s.scaledSince(ctx, name, p.at) {
+			slog.Info("skipping missed scheduled run; deployment was scaled since", "rule", p.rule.Name, "deployment", name, "due", p.at)
+			s.record(p.rule, p.at, ResultSkipped, nil)
+			continue
+		}
+		slog.Info("applying missed scheduled run", "rule", p.rule.Name, "deployment", name, "due", p.at)
+		err := s.apply(ctx, p.rule, name)
+		s.record(p.rule, p.at, resultOf(err), err)
+	}
+}
+
+// runDue fires every rule whose next run is at or before now.
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	for _, r := range s.rules {
+		s.mu.Lock()
+		st := s.status[r.Name]
+		due := st.NextRun
+		if !due.IsZero() && !due.After(now) {
+			st.NextRun = r.next(now)
+		}
+		s.mu.Unlock()
+
+		if due.IsZero() || due.After(now) {
+			continue
+		}
+
+		targets, err := s.targets(ctx, r)
+		if err != nil {
+			s.record(r, due, ResultFailed, err)
+			continue
+		}
+		var errs []error
+		for _, name := range targets {
+			if err := s.apply(ctx, r, name); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		err = errors.Join(errs...)
+		s.record(r, due, resultOf(err), err)
+	}
+}
+
+// apply scales one deployment for rule r, attributed to Caller.
+func (s *Scheduler) apply(ctx context.Context, r compiledRule, name string) error {
+	change := kube.Change{Caller: Caller, Reason: "schedule " + r.Name}
+	e := audit.Event{
+		Stage:             audit.StageAttempt,
+		Caller:            Caller,
+		Namespace:         s.opts.Namespace,
+		Deployment:        name,
+		RequestedReplicas: r.Replicas,
+		Reason:            change.Reason,
+	}
+	prev, found, err := s.opts.Store.GetReplicas(ctx, name)
+	if err == nil && found {
+		e.PreviousReplicas = &prev
+	}
+	if w, frozen := s.opts.Freezes.Check(s.opts.Namespace, s.labels(ctx, name), Caller, s.opts.Clock.Now()); frozen {
+		e.FreezeWindow = w.Name
+		slog.Info("scheduled scale refused by freeze window", "rule", r.Name, "deployment", name, "window", w.Name)
+		return s.refuse(e, &freeze.FrozenError{Window: w.Name})
+	}
+	if s.opts.Approvals.Enabled() {
+		// Without the current count there is no telling whether approval is needed.
+		switch {
+		case err != nil:
+			return fmt.Errorf("cannot determine whether the change requires approval: %w", err)
+		case !found:
+			return s.refuse(e, apierrors.NewNotFound(appsv1.Resource("deployments"), name))
+		}
+		if rule, required := s.opts.Approvals.Requires(prev, r.Replicas); required {
+			slog.Warn("scheduled scale refused; it requires approval", "rule", r.Name, "deployment", name, "replicas", r.Replicas, "approval_rule", rule)
+			return s.refuse(e, &approval.RequiredError{Rule: rule})
+		}
+	}
+	if err := s.checkPDB(ctx, name, r.Replicas); err != nil {
+		var pdbErr *kube.PDBError
+		if s.opts.PDBMode == config.PDBModeWarn && errors.As(err, &pdbErr) {
+			slog.Warn("scheduled scale-down blocks poddisruptionbudget evictions", "rule", r.Name, "deployment", name, "warning", err)
+		} else {
+			return s.refuse(e, err)
+		}
+	}
+	if err := s.opts.Auditor.Log(e); err != nil && s.opts.Auditor.FailClosed() {
+		return fmt.Errorf("audit log unavailable: %w", err)
+	}
+
+	err = s.opts.Store.SetReplicas(kube.WithChange(ctx, change), name, r.Replicas)
+
+	e.Stage = audit.StageResult
+	e.Result = audit.ResultSuccess
+	if err != nil {
+		e.Result = audit.ResultError
+		if kube.IsRejection(err) {
+			e.Result = audit.ResultRejected
+		}
+		e.Error = err.Error()
+		slog.Warn("scheduled scale failed", "rule", r.Name, "deployment", name, "replicas", r.Replicas, "error", err)
+	} else {
+		slog.Info("scheduled scale applied", "rule", r.Name, "deployment", name, "replicas", r.Replicas)
+	}
+	_ = s.opts.Auditor.Log(e)
+	return err
+}
+
+// refuse audits a scheduled change refused by policy before it was attempted, and
+// returns err.
+func (s *Scheduler) refuse(e audit.Event, err error) error {
+	_ = s.opts.Auditor.Log(e)
+	e.Stage = audit.StageResult
+	e.Result = audit.ResultRejected
+	e.Error = err.Error()
+	_ = s.opts.Auditor.Log(e)
+	return err
+}
+
+// checkPDB returns a *kube.PDBError when scaling the named deployment to replicas would
+// leave its PodDisruptionBudget allowing no disruptions, and PDBMode checks PDBs.
+func (s *Scheduler) checkPDB(ctx context.Context, name string, replicas int32) error {
+	if s.opts.PDBMode != config.PDBModeWarn && s.opts.PDBMode != config.PDBModeReject {
+		return nil
+	}
+	describer, ok := s.opts.Store.(kube.Describer)
+	if !ok {
+		return nil
+	}
+	d, ok, err := describer.DescribeDeployment(ctx, name)
+	if err != nil || !ok {
+		return err
+	}
+	return d.CheckPDB(replicas)
+}
+
+// targets resolves the deployments a rule applies to.
+func (s *Scheduler) targets(ctx context.Context, r compiledRule) ([]string, error) {
+	if r.selector == nil {
+		return []string{r.Deployment}, nil
+	}
+
+	describer, ok := s.opts.Store.(kube.Describer)
+	if !ok {
+		return nil, fmt.Errorf("store does not expose labels for selector rules")
+	}
+	names, err := s.opts.Store.ListDeployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var out []string
+	for _, name := range names {
+		d, ok, err := describer.DescribeDeployment(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok && r.selector.Matches(labels.Set(d.Labels)) {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// labels returns the cached labels of a deployment, or nil if they are unavailable.
+func (s *Scheduler) labels(ctx context.Context, name string) map[string]string {
+	describer, ok := s.opts.Store.(kube.Describer)
+	if !ok {
+		return nil
+	}
+	d, _, _ := describer.DescribeDeployment(ctx, name)
+	return d.Labels
+}
+
+// scaledSince reports whether the deployment was scaled through the service after t.
+func (s *Scheduler) scaledSince(ctx context.Context, name string, t time.Time) bool {
+	describer, ok := s.opts.Store.(kube.Describer)
+	if !ok {
+		return false
+	}
+	d, ok, err := describer.DescribeDeployment(ctx, name)
+	return err == nil && ok && d.LastScale != nil && d.LastScale.At.After(t)
+}
+
+func (s *Scheduler) record(r compiledRule, at time.Time, result string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.status[r.Name]
+	if at.After(st.LastRun) {
+		st.LastRun = at
+	}
+	st.LastResult = result
+	st.LastError = ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+}
+
+func (s *Scheduler) resetNext(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.rules {
+		s.status[r.Name].NextRun = r.next(now)
+	}
+}
+
+// nextDue returns the earliest next run across rules.
+func (s *Scheduler) nextDue() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due time.Time
+	for _, st := range s.status {
+		if !st.NextRun.IsZero() && (due.IsZero() || st.NextRun.Before(due)) {
+			due = st.NextRun
+		}
+	}
+	return due
+}
+
+func resultOf(err error) string {
+	var frozenErr *freeze.FrozenError
+	switch {
+	case err == nil:
+		return ResultApplied
+	case errors.As(err, &frozenErr):
+		return ResultSkipped
+	default:
+		return ResultFailed
+	}
+}
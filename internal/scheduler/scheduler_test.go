@@ -0,0 +1,368 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/approval"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/audit"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/config"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/freeze"
+	"github.com/BrandonSaldanha/k8-replica-manager/internal/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+type setCall struct {
+	name     string
+	replicas int32
+	change   kube.Change
+}
+
+type fakeStore struct {
+	mu    sync.Mutex
+	deps  map[string]kube.Deployment
+	calls []setCall
+	// errs are returned by SetReplicas for the named deployments.
+	errs map[string]error
+}
+
+func newFakeStore(deps ...kube.Deployment) *fakeStore {
+	f := &fakeStore{deps: map[string]kube.Deployment{}}
+	for _, d := range deps {
+		f.deps[d.Name] = d
+	}
+	return f
+}
+
+func (f *fakeStore) Ready() bool { return true }
+
+func (f *fakeStore) ListDeployments(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, 0, len(f.deps))
+	for name := range f.deps {
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func (f *fakeStore) GetReplicas(ctx context.Context, name string) (int32, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.deps[name]
+	return d.Replicas, ok, nil
+}
+
+func (f *fakeStore) DescribeDeployment(ctx context.Context, name string) (kube.Deployment, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.deps[name]
+	return d, ok, nil
+}
+
+func (f *fakeStore) SetReplicas(ctx context.Context, name string, replicas int32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.deps[name]
+	if !ok {
+		return apierrors.NewNotFound(appsv1.Resource("deployments"), name)
+	}
+	if err := f.errs[name]; err != nil {
+		return err
+	}
+	d.Replicas = replicas
+	f.deps[name] = d
+	f.calls = append(f.calls, setCall{name: name, replicas: replicas, change: kube.ChangeFromContext(ctx)})
+	return nil
+}
+
+func (f *fakeStore) setCalls() []setCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]setCall(nil), f.calls...)
+}
+
+func TestLoadRulesValidates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	ok := write("ok.yaml", `
+rules:
+  - name: night
+    deployment: web
+    replicas: 1
+    schedule: "0 20 * * 1-5"
+    timezone: Europe/Berlin
+  - name: batch
+    selector: tier=batch
+    replicas: 0
+    schedule: "@daily"
+`)
+	rules, err := LoadRules(ok)
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Timezone != "Europe/Berlin" || rules[1].Selector != "tier=batch" {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	for name, body := range map[string]string{
+		"both.yaml":     "rules: [{name: a, deployment: web, selector: x=y, replicas: 1, schedule: '@daily'}]",
+		"neither.yaml":  "rules: [{name: a, replicas: 1, schedule: '@daily'}]",
+		"cron.yaml":     "rules: [{name: a, deployment: web, replicas: 1, schedule: 'every day'}]",
+		"tz.yaml":       "rules: [{name: a, deployment: web, replicas: 1, schedule: '@daily', timezone: Mars/Olympus}]",
+		"dup.yaml":      "rules: [{name: a, deployment: web, replicas: 1, schedule: '@daily'}, {name: a, deployment: api, replicas: 1, schedule: '@daily'}]",
+		"negative.yaml": "rules: [{name: a, deployment: web, replicas: -1, schedule: '@daily'}]",
+		"unknown.yaml":  "rules: [{name: a, deployment: web, replica: 1, schedule: '@daily'}]",
+	} {
+		if _, err := LoadRules(write(name, body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSchedulerFiresOnScheduleInTimezone(t *testing.T) {
+	// 2026-03-02 is a Monday; 18:59 UTC is 19:59 in Berlin.
+	start := time.Date(2026, 3, 2, 18, 59, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(start)
+	store := newFakeStore(kube.Deployment{Name: "web", Replicas: 5})
+
+	s, err := New([]Rule{{Name: "night", Deployment: "web", Replicas: 1, Schedule: "0 20 * * 1-5", Timezone: "Europe/Berlin"}},
+		Options{Store: store, Clock: clk})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	want := time.Date(2026, 3, 2, 19, 0, 0, 0, time.UTC)
+	if got := s.Status()[0].NextRun; !got.Equal(want) {
+		t.Fatalf("NextRun = %v, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	waitFor(t, clk.HasWaiters)
+	if calls := store.setCalls(); len(calls) != 0 {
+		t.Fatalf("unexpected calls before the run: %+v", calls)
+	}
+
+	clk.Step(time.Minute)
+	waitFor(t, func() bool { return len(store.setCalls()) == 1 })
+
+	c := store.setCalls()[0]
+	if c.name != "web" || c.replicas != 1 || c.change.Caller != Caller || c.change.Reason != "schedule night" {
+		t.Fatalf("call = %+v", c)
+	}
+	waitFor(t, func() bool { return s.Status()[0].LastResult == ResultApplied })
+
+	st := s.Status()[0]
+	if !st.LastRun.Equal(want) {
+		t.Fatalf("LastRun = %v, want %v", st.LastRun, want)
+	}
+	if next := time.Date(2026, 3, 3, 19, 0, 0, 0, time.UTC); !st.NextRun.Equal(next) {
+		t.Fatalf("NextRun = %v, want %v", st.NextRun, next)
+	}
+}
+
+func TestSchedulerSelectorTargets(t *testing.T) {
+	start := time.Date(2026, 3, 2, 11, 59, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(start)
+	store := newFakeStore(
+		kube.Deployment{Name: "a", Replicas: 2, Labels: map[string]string{"tier": "batch"}},
+		kube.Deployment{Name: "b", Replicas: 2, Labels: map[string]string{"tier": "batch"}},
+		kube.Deployment{Name: "web", Replicas: 2, Labels: map[string]string{"tier": "web"}},
+	)
+
+	s, err := New([]Rule{{Name: "noon", Selector: "tier=batch", Replicas: 0, Schedule: "0 12 * * *"}},
+		Options{Store: store, Clock: clk})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	waitFor(t, clk.HasWaiters)
+	clk.Step(time.Minute)
+	waitFor(t, func() bool { return len(store.setCalls()) == 2 })
+
+	calls := store.setCalls()
+	if calls[0].name != "a" || calls[1].name != "b" {
+		t.Fatalf("calls = %+v", calls)
+	}
+}
+
+func TestSchedulerCatchUpIsDeterministic(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 30, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+	store := newFakeStore(
+		kube.Deployment{Name: "web", Replicas: 5},
+		// Scaled by hand after the missed run, so it must be left alone.
+		kube.Deployment{Name: "api", Replicas: 7, LastScale: &kube.LastScale{By: "alice", At: now.Add(-10 * time.Minute)}},
+	)
+
+	rules := []Rule{
+		// Missed at 12:00 and 12:15; only the latest run for web is applied.
+		{Name: "early", Deployment: "web", Replicas: 2, Schedule: "0 12 * * *"},
+		{Name: "late", Deployment: "web", Replicas: 3, Schedule: "15 12 * * *"},
+		// Outside the catch-up window.
+		{Name: "old", Deployment: "web", Replicas: 9, Schedule: "0 10 * * *"},
+		{Name: "api", Deployment: "api", Replicas: 1, Schedule: "0 12 * * *"},
+	}
+	s, err := New(rules, Options{Store: store, Clock: clk, CatchUpWindow: time.Hour})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+	waitFor(t, clk.HasWaiters)
+
+	calls := store.setCalls()
+	if len(calls) != 1 || calls[0].name != "web" || calls[0].replicas != 3 {
+		t.Fatalf("calls = %+v", calls)
+	}
+
+	byName := map[string]RuleStatus{}
+	for _, st := range s.Status() {
+		byName[st.Name] = st
+	}
+	if byName["late"].LastResult != ResultApplied {
+		t.Errorf("late = %+v", byName["late"])
+	}
+	if byName["api"].LastResult != ResultSkipped {
+		t.Errorf("api = %+v", byName["api"])
+	}
+	if byName["early"].LastResult != "" || byName["old"].LastResult != "" {
+		t.Errorf("early = %+v, old = %+v", byName["early"], byName["old"])
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before timeout")
+}
+
+func TestSchedulerHonoursFreezeWindows(t *testing.T) {
+	start := time.Date(2026, 3, 2, 11, 59, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(start)
+	store := newFakeStore(kube.Deployment{Name: "web", Replicas: 5})
+
+	freezeStart, freezeEnd := start.Add(-time.Hour), start.Add(time.Hour)
+	freezes, err := freeze.New([]freeze.Window{{Name: "release", Start: &freezeStart, End: &freezeEnd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New([]Rule{{Name: "noon", Deployment: "web", Replicas: 1, Schedule: "0 12 * * *"}},
+		Options{Store: store, Clock: clk, Freezes: freezes})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	waitFor(t, clk.HasWaiters)
+	clk.Step(time.Minute)
+	waitFor(t, func() bool { return s.Status()[0].LastResult != "" })
+
+	st := s.Status()[0]
+	if st.LastResult != ResultSkipped || st.LastError == "" {
+		t.Fatalf("status = %+v", st)
+	}
+	if calls := store.setCalls(); len(calls) != 0 {
+		t.Fatalf("unexpected calls during freeze: %+v", calls)
+	}
+}
+
+func TestSchedulerAppliesApprovalAndPDBPolicy(t *testing.T) {
+	start := time.Date(2026, 3, 2, 11, 59, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(start)
+	minAvailable := intstr.FromInt32(3)
+	store := newFakeStore(
+		kube.Deployment{Name: "web", Replicas: 2},
+		// Scaling to 1 leaves 2 of the PDB's pods, below its minimum of 3.
+		kube.Deployment{Name: "api", Replicas: 3, PDB: &kube.PDB{Name: "api", MinAvailable: &minAvailable, ExpectedPods: 4}},
+		kube.Deployment{Name: "worker", Replicas: 3},
+	)
+	store.errs = map[string]error{"worker": &kube.CooldownError{Name: "worker", Cooldown: time.Hour, RetryAfter: time.Minute}}
+
+	var buf bytes.Buffer
+	rules := []Rule{
+		{Name: "web-off", Deployment: "web", Replicas: 0, Schedule: "0 12 * * *"},
+		{Name: "api-down", Deployment: "api", Replicas: 1, Schedule: "0 12 * * *"},
+		{Name: "worker-down", Deployment: "worker", Replicas: 2, Schedule: "0 12 * * *"},
+	}
+	s, err := New(rules, Options{
+		Store:     store,
+		Clock:     clk,
+		Auditor:   audit.New(false, audit.NewWriterSink(&buf)),
+		Approvals: approval.Rules{ScaleToZero: true},
+		PDBMode:   config.PDBModeReject,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	waitFor(t, clk.HasWaiters)
+	clk.Step(time.Minute)
+	waitFor(t, func() bool { return s.Status()[2].LastResult != "" })
+
+	if calls := store.setCalls(); len(calls) != 0 {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+	if st := s.Status()[0]; !strings.Contains(st.LastError, "requires approval (scale to zero)") {
+		t.Fatalf("web-off = %+v", st)
+	}
+	if st := s.Status()[1]; !strings.Contains(st.LastError, "PodDisruptionBudget") {
+		t.Fatalf("api-down = %+v", st)
+	}
+
+	// Policy refusals, including those from the store, are audited as rejections.
+	rejected := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e audit.Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		if e.Stage == audit.StageResult {
+			if e.Result != audit.ResultRejected {
+				t.Fatalf("expected a rejected result, got %+v", e)
+			}
+			rejected[e.Deployment] = true
+		}
+	}
+	if len(rejected) != 3 {
+		t.Fatalf("expected 3 rejected results, got %v", rejected)
+	}
+}